@@ -6,23 +6,52 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
 )
 
 func main() {
 	http.HandleFunc("/health", healthHandler)
 	http.HandleFunc("/upload", uploadAndAnalyzeHandler)
-	http.HandleFunc("/files", proxyToService("http://file-storing-service:8082/files"))
-	http.HandleFunc("/files/", proxyToService("http://file-storing-service:8082/files/"))
-	http.HandleFunc("/analyze", proxyToService("http://file-analysis-service:8081/analyze"))
-	http.HandleFunc("/reports", proxyToService("http://file-analysis-service:8081/reports"))
-	http.HandleFunc("/reports/", proxyToService("http://file-analysis-service:8081/reports/"))
-	http.HandleFunc("/wordCloud/", proxyToService("http://file-analysis-service:8081/wordCloud/"))
+	http.HandleFunc("/uploads", tusProxyHandler)
+	http.HandleFunc("/uploads/", tusProxyHandler)
+	http.HandleFunc("/files", proxyToService(storingServiceURL()+"/files"))
+	http.HandleFunc("/files/", proxyToService(storingServiceURL()+"/files/"))
+	http.HandleFunc("/formats", proxyToService(storingServiceURL()+"/formats"))
+	http.HandleFunc("/students/", proxyToService(storingServiceURL()+"/students/"))
+	http.HandleFunc("/assignments", proxyToService(storingServiceURL()+"/assignments"))
+	http.HandleFunc("/assignments/", proxyToService(storingServiceURL()+"/assignments/"))
+	http.HandleFunc("/courses", proxyToService(storingServiceURL()+"/courses"))
+	http.HandleFunc("/courses/", proxyToService(storingServiceURL()+"/courses/"))
+	http.HandleFunc("/groups", proxyToService(storingServiceURL()+"/groups"))
+	http.HandleFunc("/groups/", proxyToService(storingServiceURL()+"/groups/"))
+	http.HandleFunc("/analyze", analyzeHandler)
+	http.HandleFunc("/reports", proxyToService(analysisServiceURL()+"/reports"))
+	http.HandleFunc("/reports/", proxyToService(analysisServiceURL()+"/reports/"))
+	http.HandleFunc("/wordCloud/", proxyToService(analysisServiceURL()+"/wordCloud/"))
+	http.HandleFunc("/corpora", proxyToService(analysisServiceURL()+"/corpora"))
 
 	fmt.Println("API Gateway запущен на http://localhost:8080")
 	http.ListenAndServe(":8080", nil)
 }
 
+// storingServiceURL и analysisServiceURL — адреса сервисов (FILE_STORING_URL и
+// FILE_ANALYSIS_URL), по умолчанию — имена контейнеров docker-compose.
+func storingServiceURL() string {
+	if url := os.Getenv("FILE_STORING_URL"); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	return "http://file-storing-service:8082"
+}
+
+func analysisServiceURL() string {
+	if url := os.Getenv("FILE_ANALYSIS_URL"); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	return "http://file-analysis-service:8081"
+}
+
 func healthHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
@@ -33,14 +62,18 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 func proxyToService(targetURL string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusOK)
 			return
 		}
-		fullURL := targetURL + r.RequestURI[len(getBasePath(targetURL)):]
+		// Путь и строка запроса (фильтры, cursor) передаются сервису без изменений
+		fullURL := targetURL + strings.TrimPrefix(r.URL.EscapedPath(), getBasePath(targetURL))
+		if r.URL.RawQuery != "" {
+			fullURL += "?" + r.URL.RawQuery
+		}
 		req, err := http.NewRequest(r.Method, fullURL, r.Body)
 		if err != nil {
 			http.Error(w, "Ошибка создания прокси-запроса", http.StatusInternalServerError)
@@ -55,6 +88,7 @@ func proxyToService(targetURL string) http.HandlerFunc {
 		resp, err := client.Do(req)
 		if err != nil {
 			http.Error(w, "Сервис недоступен", http.StatusBadGateway)
+			return
 		}
 		defer resp.Body.Close()
 		for name, values := range resp.Header {
@@ -93,59 +127,170 @@ func uploadAndAnalyzeHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	resp, err := http.Post(
-		"http://file-storing-service:8082/upload",
-		r.Header.Get("Content-Type"),
-		r.Body,
-	)
+	// Тело передаётся сервису хранения потоком, по мере чтения от клиента. Если клиент
+	// оборвал загрузку, контекст запроса отменяет и запрос к сервису хранения.
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, storingServiceURL()+"/upload", r.Body)
+	if err != nil {
+		http.Error(w, "Ошибка создания прокси-запроса", http.StatusInternalServerError)
+		return
+	}
+	req.Header.Set("Content-Type", r.Header.Get("Content-Type"))
+	req.ContentLength = r.ContentLength
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		http.Error(w, "Ошибка связи с File Storing Service", http.StatusBadGateway)
 		return
 	}
 	defer resp.Body.Close()
-	bodyBytes, _ := io.ReadAll(resp.Body)
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(resp.StatusCode)
 	if resp.StatusCode != http.StatusOK {
-		w.Write(bodyBytes)
+		// Сервис хранения прервал приём (например, по лимиту размера): остаток тела не нужен
+		if resp.Close {
+			w.Header().Set("Connection", "close")
+		}
+		w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
 		return
 	}
+	bodyBytes, _ := io.ReadAll(resp.Body)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.StatusCode)
 	var uploadResp map[string]interface{}
 	json.Unmarshal(bodyBytes, &uploadResp)
 
-	go func() {
-		var fileID int
-		if idFloat, ok := uploadResp["file_id"].(float64); ok {
-			fileID = int(idFloat)
-		} else {
-			if idInt, ok := uploadResp["file_id"].(int); ok {
-				fileID = idInt
-			} else {
-				fmt.Println("Ошибка: не удалось получить file_id из ответа, он равен 0")
-				return
-			}
-		}
-		filePath, _ := uploadResp["file_path"].(string)
-		studentID, _ := uploadResp["student_id"].(string)
-		assignmentID, _ := uploadResp["assignment_id"].(string)
-
-		analyzeReq := map[string]interface{}{
-			"file_id":       int(fileID),
-			"file_path":     filePath,
-			"student_id":    studentID,
-			"assignment_id": assignmentID,
+	var fileID int
+	if idFloat, ok := uploadResp["file_id"].(float64); ok {
+		fileID = int(idFloat)
+	} else {
+		fmt.Println("Ошибка: не удалось получить file_id из ответа, он равен 0")
+	}
+	if fileID != 0 {
+		go startAnalysis(fileID)
+	}
+	uploadResp["analysis_status"] = "started"
+	finalResponse, _ := json.Marshal(uploadResp)
+	w.Write(finalResponse)
+}
+
+// enqueueAnalysis ставит работу в очередь на анализ через внутренний API сервиса хранения
+// и возвращает код его ответа и текст ответа. Если работа уже в очереди или анализируется,
+// сервис хранения отвечает 409 Conflict.
+func enqueueAnalysis(fileID int) (int, string) {
+	jsonData, _ := json.Marshal(map[string]string{"status": "queued"})
+	url := fmt.Sprintf("%s/internal/files/%d/status", storingServiceURL(), fileID)
+	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return http.StatusBadGateway, "Ошибка связи с File Storing Service"
+	}
+	defer resp.Body.Close()
+	message, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, strings.TrimSpace(string(message))
+}
+
+// analyzeHandler ставит работу в очередь и передаёт запрос сервису анализа.
+func analyzeHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Ошибка чтения запроса", http.StatusBadRequest)
+		return
+	}
+	var req struct {
+		FileID int `json:"file_id"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil || req.FileID <= 0 {
+		http.Error(w, `Ошибка при парсинге JSON`, http.StatusBadRequest)
+		return
+	}
+	if code, message := enqueueAnalysis(req.FileID); code != http.StatusOK {
+		http.Error(w, message, code)
+		return
+	}
+	// Если сервис анализа недоступен, работа остаётся в очереди: повторный запрос поставит
+	// её туда заново, когда она будет считаться зависшей
+	resp, err := http.Post(analysisServiceURL()+"/analyze", "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		http.Error(w, "Сервис недоступен", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	for name, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(name, value)
 		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// startAnalysis ставит загруженную работу в очередь и запускает анализ. Остальное о работе
+// сервис анализа получит у сервиса хранения.
+func startAnalysis(fileID int) {
+	if code, message := enqueueAnalysis(fileID); code != http.StatusOK {
+		fmt.Printf("Ошибка постановки файла %d в очередь: %d %s\n", fileID, code, message)
+		return
+	}
+	jsonData, _ := json.Marshal(map[string]interface{}{
+		"file_id": fileID,
+	})
+	_, err := http.Post(analysisServiceURL()+"/analyze", "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		fmt.Printf("Ошибка авто-запуска анализа для файла %d: %v\n", fileID, err)
+	} else {
+		fmt.Printf("Авто-анализ запущен для файла %d\n", fileID)
+	}
+}
 
-		jsonData, _ := json.Marshal(analyzeReq)
-		_, err := http.Post("http://file-analysis-service:8081/analyze", "application/json", bytes.NewBuffer(jsonData))
+// tusProxyHandler проксирует возобновляемую загрузку (tus) в File Storing Service вместе
+// с запросами OPTIONS. Когда последний PATCH регистрирует работу, сервис хранения
+// возвращает её ID в Upload-File-Id, и gateway запускает анализ, как для /upload.
+func tusProxyHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, HEAD, PATCH, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers",
+		"Content-Type, Authorization, Tus-Resumable, Upload-Length, Upload-Metadata, Upload-Offset, Upload-Defer-Length, X-HTTP-Method-Override")
+	w.Header().Set("Access-Control-Expose-Headers",
+		"Location, Tus-Resumable, Tus-Version, Tus-Extension, Tus-Max-Size, Upload-Offset, Upload-Length, Upload-Expires, Upload-File-Id")
 
-		if err != nil {
-			fmt.Printf("Ошибка авто-запуска анализа для файла %d: %v\n", int(fileID), err)
-		} else {
-			fmt.Printf("Авто-анализ запущен для файла %d\n", int(fileID))
+	req, err := http.NewRequest(r.Method, storingServiceURL()+r.URL.EscapedPath(), r.Body)
+	if err != nil {
+		http.Error(w, "Ошибка создания прокси-запроса", http.StatusInternalServerError)
+		return
+	}
+	req.Header = r.Header.Clone()
+	req.ContentLength = r.ContentLength
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		http.Error(w, "Ошибка связи с File Storing Service", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	for name, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(name, value)
 		}
-	}()
-	uploadResp["analysis_status"] = "started"
-	finalResponse, _ := json.Marshal(uploadResp)
-	w.Write(finalResponse)
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+
+	method := r.Method
+	if override := r.Header.Get("X-HTTP-Method-Override"); override != "" {
+		method = override
+	}
+	if method == http.MethodPatch && resp.StatusCode == http.StatusNoContent {
+		if fileID, err := strconv.Atoi(resp.Header.Get("Upload-File-Id")); err == nil {
+			go startAnalysis(fileID)
+		}
+	}
 }
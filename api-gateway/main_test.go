@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeServices — сервис хранения, который ведёт статус одной работы по правилам
+// /internal/files/{id}/status, и сервис анализа, который только считает запросы.
+type fakeServices struct {
+	mu       sync.Mutex
+	status   string
+	analyzed int
+}
+
+func startFakeServices(t *testing.T, status string) *fakeServices {
+	t.Helper()
+	f := &fakeServices{status: status}
+	storing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if r.Method != http.MethodPost || r.URL.Path != "/internal/files/7/status" {
+			http.Error(w, "Файл не найден", http.StatusNotFound)
+			return
+		}
+		var req struct {
+			Status string `json:"status"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Status != "queued" || f.status == "queued" || f.status == "analyzing" {
+			http.Error(w, "Недопустимый переход статуса: переход из "+f.status+" в "+req.Status+" запрещён", http.StatusConflict)
+			return
+		}
+		f.status = req.Status
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": "7", "status": req.Status})
+	}))
+	analysis := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.analyzed++
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"file_id": 7, "analysis_state": "completed"}`)
+	}))
+	t.Cleanup(storing.Close)
+	t.Cleanup(analysis.Close)
+	t.Setenv("FILE_STORING_URL", storing.URL+"/")
+	t.Setenv("FILE_ANALYSIS_URL", analysis.URL)
+	return f
+}
+
+func TestEnqueueAnalysis(t *testing.T) {
+	f := startFakeServices(t, "uploaded")
+	if code, message := enqueueAnalysis(7); code != http.StatusOK {
+		t.Fatalf("uploaded → queued: %d %s", code, message)
+	}
+	if f.status != "queued" {
+		t.Fatalf("статус %s, ожидался queued", f.status)
+	}
+	// Работа уже в очереди: повторный запуск отклоняется с сообщением сервиса хранения
+	code, message := enqueueAnalysis(7)
+	if code != http.StatusConflict || !strings.Contains(message, "из queued в queued") {
+		t.Fatalf("повторная постановка в очередь: %d %s", code, message)
+	}
+	if code, _ := enqueueAnalysis(8); code != http.StatusNotFound {
+		t.Fatalf("несуществующая работа: %d", code)
+	}
+
+	t.Setenv("FILE_STORING_URL", "http://127.0.0.1:1")
+	if code, _ := enqueueAnalysis(7); code != http.StatusBadGateway {
+		t.Fatalf("недоступный сервис хранения: %d", code)
+	}
+}
+
+func TestAnalyzeHandler(t *testing.T) {
+	cases := []struct {
+		name     string
+		status   string
+		body     string
+		code     int
+		analyzed int
+	}{
+		{"загруженная работа", "uploaded", `{"file_id": 7}`, http.StatusOK, 1},
+		{"повторный анализ завершённой", "completed", `{"file_id": 7}`, http.StatusOK, 1},
+		{"работа уже анализируется", "analyzing", `{"file_id": 7}`, http.StatusConflict, 0},
+		{"работа уже в очереди", "queued", `{"file_id": 7}`, http.StatusConflict, 0},
+		{"без file_id", "uploaded", `{}`, http.StatusBadRequest, 0},
+	}
+	for _, c := range cases {
+		f := startFakeServices(t, c.status)
+		w := httptest.NewRecorder()
+		analyzeHandler(w, httptest.NewRequest(http.MethodPost, "/analyze", strings.NewReader(c.body)))
+		if w.Code != c.code || f.analyzed != c.analyzed {
+			t.Errorf("%s: код %d, запросов к анализу %d; ожидалось %d и %d", c.name, w.Code, f.analyzed, c.code, c.analyzed)
+		}
+		if c.code == http.StatusOK && !strings.Contains(w.Body.String(), `"analysis_state"`) {
+			t.Errorf("%s: ответ сервиса анализа не передан: %s", c.name, w.Body)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+// Команда evaluate прогоняет все зарегистрированные алгоритмы сходства
+// по размеченному корпусу пар и печатает precision, recall, ROC-AUC
+// и лучший порог для каждого языка.
+//
+// Ожидаемая структура корпуса:
+//
+//	corpus/
+//	  plagiarised/<пара>/{a.py,b.py}
+//	  independent/<пара>/{a.py,b.py}
+//	  obfuscated/<пара>/{a.py,b.py}
+//
+// Пары из plagiarised и obfuscated считаются положительными, из independent — отрицательными.
+// Язык пары берётся из реестра форматов (FORMATS_CONFIG или ../formats.json при запуске
+// из каталога сервиса), пары сравниваются тем же similarity.Compare, что и в сервисе.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"file-analysis-service/similarity"
+	"shared/formats"
+)
+
+var labels = map[string]bool{
+	"plagiarised": true,
+	"obfuscated":  true,
+	"independent": false,
+}
+
+type pair struct {
+	language string
+	label    string
+	first    string
+	second   string
+}
+
+type sample struct {
+	score    float64
+	positive bool
+}
+
+type metrics struct {
+	precision float64
+	recall    float64
+	auc       float64
+	threshold float64
+}
+
+func main() {
+	dir := flag.String("dir", "corpus", "каталог с размеченными парами")
+	algo := flag.String("algo", "", "проверить только указанный алгоритм")
+	flag.Parse()
+	formats.Load()
+
+	pairs, err := loadPairs(*dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Ошибка чтения корпуса:", err)
+		os.Exit(1)
+	}
+	if len(pairs) == 0 {
+		fmt.Fprintln(os.Stderr, "В корпусе не найдено ни одной пары")
+		os.Exit(1)
+	}
+
+	names := similarity.Names()
+	if *algo != "" {
+		if _, ok := similarity.Get(*algo); !ok {
+			fmt.Fprintf(os.Stderr, "Алгоритм %s не зарегистрирован. Доступны: %s\n", *algo, strings.Join(names, ", "))
+			os.Exit(1)
+		}
+		names = []string{*algo}
+	}
+
+	byLanguage := map[string][]pair{}
+	for _, p := range pairs {
+		byLanguage[p.language] = append(byLanguage[p.language], p)
+	}
+	langs := make([]string, 0, len(byLanguage))
+	for lang := range byLanguage {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "LANGUAGE\tALGORITHM\tPAIRS\tPRECISION\tRECALL\tROC-AUC\tTHRESHOLD")
+	for _, lang := range langs {
+		for _, name := range names {
+			fn, _ := similarity.Get(name)
+			samples, err := score(byLanguage[lang], fn)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Ошибка чтения пары:", err)
+				os.Exit(1)
+			}
+			m := evaluate(samples)
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%.3f\t%.3f\t%.3f\t%.2f\n",
+				lang, name, len(samples), m.precision, m.recall, m.auc, m.threshold)
+		}
+	}
+	tw.Flush()
+}
+
+func loadPairs(dir string) ([]pair, error) {
+	var pairs []pair
+	for label := range labels {
+		labelDir := filepath.Join(dir, label)
+		entries, err := os.ReadDir(labelDir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			pairDir := filepath.Join(labelDir, entry.Name())
+			files, err := os.ReadDir(pairDir)
+			if err != nil {
+				return nil, err
+			}
+			var members []string
+			for _, f := range files {
+				if !f.IsDir() {
+					members = append(members, filepath.Join(pairDir, f.Name()))
+				}
+			}
+			if len(members) != 2 {
+				fmt.Fprintf(os.Stderr, "Пропуск %s: ожидалось 2 файла, найдено %d\n", pairDir, len(members))
+				continue
+			}
+			lang := "other"
+			if f := formats.For(members[0]); f != nil && f.Language != "" {
+				lang = f.Language
+			}
+			pairs = append(pairs, pair{language: lang, label: label, first: members[0], second: members[1]})
+		}
+	}
+	return pairs, nil
+}
+
+func score(pairs []pair, fn similarity.Func) ([]sample, error) {
+	samples := make([]sample, 0, len(pairs))
+	for _, p := range pairs {
+		first, err := os.ReadFile(p.first)
+		if err != nil {
+			return nil, err
+		}
+		second, err := os.ReadFile(p.second)
+		if err != nil {
+			return nil, err
+		}
+		// Пара сравнивается так же, как работы в сервисе анализа: по токенизаторам
+		// реестра форматов, без комментариев в коде и с разбором блокнотов
+		a := similarity.ReadMember(first, filepath.Base(p.first))
+		b := similarity.ReadMember(second, filepath.Base(p.second))
+		samples = append(samples, sample{
+			score:    similarity.Compare(fn, []similarity.Member{a}, []similarity.Member{b}),
+			positive: labels[p.label],
+		})
+	}
+	return samples, nil
+}
+
+// evaluate выбирает порог с максимальной F1-мерой и считает ROC-AUC
+// как вероятность того, что положительная пара получит оценку выше отрицательной.
+func evaluate(samples []sample) metrics {
+	sort.Slice(samples, func(i, j int) bool { return samples[i].score > samples[j].score })
+
+	positives, negatives := 0, 0
+	for _, s := range samples {
+		if s.positive {
+			positives++
+		} else {
+			negatives++
+		}
+	}
+
+	var best metrics
+	bestF1 := -1.0
+	tp, fp := 0, 0
+	for i, s := range samples {
+		if s.positive {
+			tp++
+		} else {
+			fp++
+		}
+		if i+1 < len(samples) && samples[i+1].score == s.score {
+			continue
+		}
+		precision := float64(tp) / float64(tp+fp)
+		recall := 0.0
+		if positives > 0 {
+			recall = float64(tp) / float64(positives)
+		}
+		f1 := 0.0
+		if precision+recall > 0 {
+			f1 = 2 * precision * recall / (precision + recall)
+		}
+		if f1 > bestF1 {
+			bestF1 = f1
+			best = metrics{precision: precision, recall: recall, threshold: s.score}
+		}
+	}
+
+	if positives > 0 && negatives > 0 {
+		wins := 0.0
+		for _, p := range samples {
+			if !p.positive {
+				continue
+			}
+			for _, n := range samples {
+				if n.positive {
+					continue
+				}
+				if p.score > n.score {
+					wins++
+				} else if p.score == n.score {
+					wins += 0.5
+				}
+			}
+		}
+		best.auc = wins / float64(positives*negatives)
+	}
+	return best
+}
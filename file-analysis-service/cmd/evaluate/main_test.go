@@ -0,0 +1,86 @@
+package main
+
+import (
+	"math"
+	"os"
+	"testing"
+
+	"file-analysis-service/similarity"
+	"shared/formats"
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv("FORMATS_CONFIG") == "" {
+		os.Setenv("FORMATS_CONFIG", "../../../formats.json")
+	}
+	formats.Load()
+	os.Exit(m.Run())
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestEvaluateSeparable(t *testing.T) {
+	m := evaluate([]sample{
+		{0.9, true}, {0.2, false}, {0.8, true}, {0.1, false},
+	})
+	if !near(m.precision, 1) || !near(m.recall, 1) || !near(m.auc, 1) || !near(m.threshold, 0.8) {
+		t.Fatalf("evaluate = %+v, want precision 1, recall 1, auc 1, threshold 0.8", m)
+	}
+}
+
+func TestEvaluateTiesAndMistakes(t *testing.T) {
+	// Положительная 0.5 и отрицательная 0.5 неразличимы, отрицательная 0.7 выше положительной 0.5.
+	m := evaluate([]sample{
+		{0.9, true}, {0.7, false}, {0.5, true}, {0.5, false},
+	})
+	// Пары (0.9 > 0.7), (0.9 > 0.5), (0.5 < 0.7), (0.5 = 0.5): (1 + 1 + 0 + 0.5) / 4
+	if !near(m.auc, 0.625) {
+		t.Fatalf("auc = %v, want 0.625", m.auc)
+	}
+	// F1 при порогах 0.9 и 0.5 одинакова (2/3): остаётся более высокий порог
+	if !near(m.threshold, 0.9) || !near(m.precision, 1) || !near(m.recall, 0.5) {
+		t.Fatalf("evaluate = %+v, want threshold 0.9, precision 1, recall 0.5", m)
+	}
+}
+
+func TestEvaluateOneClass(t *testing.T) {
+	m := evaluate([]sample{{0.4, true}, {0.3, true}})
+	if m.auc != 0 {
+		t.Fatalf("auc без отрицательных пар = %v, want 0", m.auc)
+	}
+	if !near(m.recall, 1) || !near(m.threshold, 0.3) {
+		t.Fatalf("evaluate = %+v, want recall 1 at threshold 0.3", m)
+	}
+}
+
+func TestSampleCorpus(t *testing.T) {
+	pairs, err := loadPairs("testdata/corpus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	byLabel := map[string]int{}
+	byLanguage := map[string][]pair{}
+	for _, p := range pairs {
+		byLabel[p.label]++
+		byLanguage[p.language] = append(byLanguage[p.language], p)
+	}
+	if byLabel["plagiarised"] != 3 || byLabel["obfuscated"] != 2 || byLabel["independent"] != 4 {
+		t.Fatalf("пары по меткам: %v", byLabel)
+	}
+	if len(byLanguage["python"]) != 5 || len(byLanguage["go"]) != 4 {
+		t.Fatalf("пары по языкам: python %d, go %d", len(byLanguage["python"]), len(byLanguage["go"]))
+	}
+
+	words, _ := similarity.Get("words")
+	for lang, langPairs := range byLanguage {
+		samples, err := score(langPairs, words)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m := evaluate(samples); m.auc < 0.9 {
+			t.Errorf("%s: ROC-AUC алгоритма words на образцах %v, ожидалось не меньше 0.9", lang, m.auc)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+package main
+
+import "sort"
+
+func largest(values []int) int {
+	sort.Ints(values)
+	return values[len(values)-1]
+}
@@ -0,0 +1,10 @@
+package main
+
+func max(xs ...int) (m int) {
+	for idx, x := range xs {
+		if idx == 0 || x > m {
+			m = x
+		}
+	}
+	return
+}
@@ -0,0 +1,9 @@
+package main
+
+func reversed(in []int) []int {
+	out := make([]int, 0, len(in))
+	for k := len(in) - 1; k >= 0; k-- {
+		out = append(out, in[k])
+	}
+	return out
+}
@@ -0,0 +1,6 @@
+from functools import lru_cache
+
+
+@lru_cache(maxsize=None)
+def fibonacci(k):
+    return k if k < 2 else fibonacci(k - 1) + fibonacci(k - 2)
@@ -0,0 +1,12 @@
+def is_prime(x):
+    if x < 2:
+        return False
+    d = 2
+    while d * d <= x:
+        if x % d == 0:
+            return False
+        d += 1
+    return True
+
+
+print([x for x in range(100) if is_prime(x)])
@@ -0,0 +1,7 @@
+def sort_values(values):
+    size = len(values)
+    for i in range(size):
+        for j in range(size - i - 1):
+            if values[j] > values[j + 1]:
+                values[j], values[j + 1] = values[j + 1], values[j]
+    return values
@@ -0,0 +1,9 @@
+def fib(n):
+    # числа Фибоначчи
+    a, b = 0, 1
+    for _ in range(n):
+        a, b = b, a + b
+    return a
+
+
+print(fib(int(input())))
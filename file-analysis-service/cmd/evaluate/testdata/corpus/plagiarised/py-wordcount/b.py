@@ -0,0 +1,9 @@
+import sys
+from collections import Counter
+
+counts = Counter()
+for line in sys.stdin:
+    counts.update(line.lower().split())
+
+for word, count in counts.most_common(10):
+    print(word, count)
@@ -0,0 +1,442 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"file-analysis-service/similarity"
+	"shared/formats"
+)
+
+// CorpusInfo — корпус эталонных решений (прошлые годы, решения из интернета).
+// Эталоны хранятся в таблице reference_files, а не в files, чтобы не считаться работами студентов.
+type CorpusInfo struct {
+	Name         string `json:"name"`
+	AssignmentID string `json:"assignment_id"`
+	FilesCount   int    `json:"files_count"`
+}
+
+// Лимиты импорта корпуса из архива, присланного через API.
+var (
+	corpusMaxFiles     = envInt64("CORPUS_MAX_FILES", 20000)
+	corpusMaxTotalSize = envInt64("CORPUS_MAX_TOTAL_SIZE", 500<<20)
+)
+
+var errCorpusLimit = errors.New("превышены лимиты корпуса")
+
+func envInt64(name string, def int64) int64 {
+	value, err := strconv.ParseInt(os.Getenv(name), 10, 64)
+	if err != nil || value <= 0 {
+		return def
+	}
+	return value
+}
+
+func corporaDir() string {
+	dir := "/app/corpora"
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		dir = "./corpora"
+	}
+	return dir
+}
+
+// importCorpus загружает эталонные решения из каталога или tar-архива (.tar, .tar.gz, .tgz).
+// Повторный импорт заменяет содержимое корпуса, а не добавляет эталоны заново.
+func importCorpus(name string, assignmentID string, source string) (int, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return 0, err
+	}
+	if info.IsDir() {
+		return importDirectory(name, assignmentID, source)
+	}
+	f, err := os.Open(source)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return importTar(name, assignmentID, f, isGzipName(source))
+}
+
+func importDirectory(name string, assignmentID string, dir string) (int, error) {
+	imp, err := newCorpusImport(name, assignmentID)
+	if err != nil {
+		return 0, err
+	}
+	defer imp.discard()
+	err = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !formats.IsSourceFile(rel) {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = imp.save(rel, f, info.Size())
+		return err
+	})
+	if err != nil {
+		return len(imp.paths), err
+	}
+	return len(imp.paths), imp.commit()
+}
+
+func importTar(name string, assignmentID string, r io.Reader, gz bool) (int, error) {
+	if gz {
+		gzr, err := gzip.NewReader(r)
+		if err != nil {
+			return 0, err
+		}
+		defer gzr.Close()
+		r = gzr
+	}
+	imp, err := newCorpusImport(name, assignmentID)
+	if err != nil {
+		return 0, err
+	}
+	defer imp.discard()
+	tr := tar.NewReader(r)
+	entries := int64(0)
+	var written int64
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return len(imp.paths), err
+		}
+		if header.Typeflag == tar.TypeDir {
+			continue
+		}
+		entries++
+		if entries > corpusMaxFiles {
+			return len(imp.paths), fmt.Errorf("%w: больше %d файлов", errCorpusLimit, corpusMaxFiles)
+		}
+		if header.Typeflag != tar.TypeReg {
+			fmt.Printf("Пропуск %s: символические ссылки и специальные файлы не поддерживаются\n", header.Name)
+			continue
+		}
+		rel := filepath.Clean(header.Name)
+		if filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			fmt.Printf("Пропуск %s: недопустимый путь в архиве\n", header.Name)
+			continue
+		}
+		if !formats.IsSourceFile(rel) {
+			continue
+		}
+		br := bufio.NewReaderSize(tr, 8000)
+		head, _ := br.Peek(8000)
+		if bytes.IndexByte(head, 0) != -1 {
+			fmt.Printf("Пропуск %s: бинарный файл\n", header.Name)
+			continue
+		}
+		n, err := imp.save(rel, br, corpusMaxTotalSize-written)
+		if err != nil {
+			return len(imp.paths), err
+		}
+		written += n
+	}
+	return len(imp.paths), imp.commit()
+}
+
+// corpusImport собирает эталоны во временном каталоге рядом с корпусами; commit заменяет
+// ими прежнее содержимое корпуса на диске и в БД. Эталоны с тем же путём сохраняют ID, на
+// который могут ссылаться отчёты, а эталоны, которых нет в новой версии, удаляются. Если
+// импорт не удался, корпус остаётся прежним.
+type corpusImport struct {
+	name         string
+	assignmentID string
+	staging      string
+	paths        []string
+}
+
+func newCorpusImport(name string, assignmentID string) (*corpusImport, error) {
+	if err := os.MkdirAll(corporaDir(), 0755); err != nil {
+		return nil, err
+	}
+	staging, err := os.MkdirTemp(corporaDir(), ".import-"+name+"-")
+	if err != nil {
+		return nil, err
+	}
+	return &corpusImport{name: name, assignmentID: assignmentID, staging: staging}, nil
+}
+
+// save сохраняет эталон во временный каталог. Если файл больше maxSize, возвращается
+// errCorpusLimit.
+func (imp *corpusImport) save(rel string, content io.Reader, maxSize int64) (int64, error) {
+	path := filepath.Join(imp.staging, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, err
+	}
+	dst, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer dst.Close()
+	n, err := io.Copy(dst, io.LimitReader(content, maxSize+1))
+	if err != nil {
+		return n, err
+	}
+	if n > maxSize {
+		return n, fmt.Errorf("%w: распакованный размер больше %d байт", errCorpusLimit, corpusMaxTotalSize)
+	}
+	imp.paths = append(imp.paths, filepath.ToSlash(rel))
+	return n, nil
+}
+
+// commit записывает эталоны в reference_files и подменяет каталог корпуса одной операцией:
+// если транзакция не проходит, прежний каталог возвращается на место.
+func (imp *corpusImport) commit() error {
+	dir, err := filepath.Abs(filepath.Join(corporaDir(), imp.name))
+	if err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	query := `
+	INSERT INTO reference_files (corpus, assignment_id, source_path, file_path)
+	VALUES (?, ?, ?, ?)
+	ON CONFLICT (corpus, source_path) DO UPDATE SET assignment_id = excluded.assignment_id, file_path = excluded.file_path
+	`
+	for _, rel := range imp.paths {
+		if _, err := tx.Exec(query, imp.name, imp.assignmentID, rel, filepath.Join(dir, filepath.FromSlash(rel))); err != nil {
+			return err
+		}
+	}
+	paths, _ := json.Marshal(imp.paths)
+	_, err = tx.Exec(`DELETE FROM reference_files WHERE corpus = ? AND source_path NOT IN (SELECT value FROM json_each(?))`, imp.name, string(paths))
+	if err != nil {
+		return err
+	}
+
+	previous := imp.staging + ".old"
+	hadPrevious := false
+	if _, err := os.Stat(dir); err == nil {
+		if err := os.Rename(dir, previous); err != nil {
+			return err
+		}
+		hadPrevious = true
+	}
+	restore := func() {
+		if hadPrevious {
+			os.Rename(previous, dir)
+		}
+	}
+	if err := os.Rename(imp.staging, dir); err != nil {
+		restore()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		os.Rename(dir, imp.staging)
+		restore()
+		return err
+	}
+	os.RemoveAll(previous)
+	return nil
+}
+
+// discard удаляет временный каталог, если импорт не дошёл до commit.
+func (imp *corpusImport) discard() {
+	os.RemoveAll(imp.staging)
+}
+
+func isGzipName(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz")
+}
+
+func validCorpusName(name string) bool {
+	if name == "" || name == "." || name == ".." {
+		return false
+	}
+	return !strings.ContainsAny(name, `/\`)
+}
+
+func corporaHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		listCorporaHandler(w, r)
+	case http.MethodPost:
+		importCorpusHandler(w, r)
+	default:
+		http.Error(w, "Only GET and POST methods are supported.", http.StatusMethodNotAllowed)
+	}
+}
+
+func listCorporaHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	query := `
+	SELECT corpus, assignment_id, COUNT(*)
+	FROM reference_files
+	GROUP BY corpus, assignment_id
+	ORDER BY corpus
+	`
+	rows, err := db.Query(query)
+	if err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	corpora := []CorpusInfo{}
+	for rows.Next() {
+		var corpus CorpusInfo
+		if err := rows.Scan(&corpus.Name, &corpus.AssignmentID, &corpus.FilesCount); err != nil {
+			continue
+		}
+		corpora = append(corpora, corpus)
+	}
+	json.NewEncoder(w).Encode(corpora)
+}
+
+func importCorpusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	err := r.ParseMultipartForm(64 << 20)
+	if err != nil {
+		http.Error(w, `Ошибка при парсинге формы`, http.StatusBadRequest)
+		return
+	}
+	name := r.FormValue("name")
+	assignmentID := r.FormValue("assignment_id")
+	if !validCorpusName(name) {
+		http.Error(w, `Некорректное имя корпуса`, http.StatusBadRequest)
+		return
+	}
+	file, handler, err := r.FormFile("archive")
+	if err != nil {
+		http.Error(w, `Архив не найден в запросе`, http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	lower := strings.ToLower(handler.Filename)
+	if !strings.HasSuffix(lower, ".tar") && !isGzipName(lower) {
+		http.Error(w, `Поддерживаются только архивы .tar, .tar.gz и .tgz`, http.StatusUnsupportedMediaType)
+		return
+	}
+	count, err := importTar(name, assignmentID, file, isGzipName(lower))
+	if errors.Is(err, errCorpusLimit) {
+		fmt.Println("Корпус отклонён:", err)
+		http.Error(w, fmt.Sprintf(`Корпус отклонён после чтения %d файлов, прежнее содержимое корпуса сохранено: %v`, count, err), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		fmt.Println("Ошибка импорта корпуса:", err)
+		http.Error(w, `Ошибка при импорте корпуса`, http.StatusBadRequest)
+		return
+	}
+	response := map[string]interface{}{
+		"status":        "success",
+		"corpus":        name,
+		"assignment_id": assignmentID,
+		"imported":      count,
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// compareWithCorpora сравнивает работу с эталонными решениями. Если список корпусов
+// не задан, используются корпуса этого задания и корпуса без привязки к заданию.
+func compareWithCorpora(members []similarity.Member, assignmentID string, corpora []string) (float64, int) {
+	var rows *sql.Rows
+	var err error
+	if len(corpora) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(corpora)), ",")
+		args := make([]interface{}, len(corpora))
+		for i, c := range corpora {
+			args[i] = c
+		}
+		query := `SELECT id, file_path FROM reference_files WHERE corpus IN (` + placeholders + `) ORDER BY id ASC`
+		rows, err = db.Query(query, args...)
+	} else {
+		query := `SELECT id, file_path FROM reference_files WHERE assignment_id = ? OR assignment_id = '' ORDER BY id ASC`
+		rows, err = db.Query(query, assignmentID)
+	}
+	if err != nil {
+		fmt.Println("Ошибка при запросе к БД", err)
+		return 0, 0
+	}
+	defer rows.Close()
+
+	maxSimilarity := 0.0
+	matchedReferenceID := 0
+	for rows.Next() {
+		var referenceID int
+		var filePath string
+		if err := rows.Scan(&referenceID, &filePath); err != nil {
+			continue
+		}
+		// Корпуса хранятся на диске самого сервиса анализа, а не в хранилище работ
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			fmt.Printf("Ошибка чтения эталона %s: %v\n", filePath, err)
+			continue
+		}
+		reference := similarity.ReadMember(content, filepath.Base(filePath))
+		score := similarity.Compare(algorithm, members, []similarity.Member{reference})
+		if score > maxSimilarity {
+			maxSimilarity = score
+			matchedReferenceID = referenceID
+		}
+	}
+	fmt.Printf("Максимальное совпадение с эталонами: %.2f%% (Reference ID: %d)\n", maxSimilarity*100, matchedReferenceID)
+	return maxSimilarity, matchedReferenceID
+}
+
+func referenceLabel(referenceID int) string {
+	var corpus, sourcePath string
+	err := db.QueryRow(`SELECT corpus, source_path FROM reference_files WHERE id = ?`, referenceID).Scan(&corpus, &sourcePath)
+	if err != nil {
+		return fmt.Sprintf("Reference ID %d", referenceID)
+	}
+	return fmt.Sprintf("эталоном %s/%s", corpus, sourcePath)
+}
+
+// runImportCorpus — подкоманда import-corpus:
+//
+//	file-analysis import-corpus -name 2023 -assignment task-001 ./solutions.tar.gz
+func runImportCorpus(args []string) {
+	fs := flag.NewFlagSet("import-corpus", flag.ExitOnError)
+	name := fs.String("name", "", "имя корпуса")
+	assignmentID := fs.String("assignment", "", "задание, к которому относится корпус (пусто — ко всем)")
+	fs.Parse(args)
+	if !validCorpusName(*name) || fs.NArg() != 1 {
+		fmt.Println("Использование: import-corpus -name <корпус> [-assignment <задание>] <каталог или архив>")
+		os.Exit(2)
+	}
+	count, err := importCorpus(*name, *assignmentID, fs.Arg(0))
+	if err != nil {
+		fmt.Println("Ошибка импорта корпуса:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Импортировано %d эталонных решений в корпус %s\n", count, *name)
+}
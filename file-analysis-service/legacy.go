@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Раньше сервис анализа держал отчёты в общей с сервисом хранения БД files.db. Подкоманда
+// import-legacy переносит оттуда отчёты, совпадения файлов проектов и эталоны в БД сервиса
+// анализа, сохраняя их ID; студент и задание отчёта берутся из таблицы files:
+//
+//	file-analysis import-legacy /app/files.db
+//
+// Переносить можно только в пустую БД, чтобы не перепутать ID.
+
+// legacyTables — что переносится, в порядке переноса: таблица и SELECT по старой БД.
+// Эталоны, импортированные в старую БД повторно, переносятся по одному на путь.
+var legacyTables = []struct {
+	table   string
+	columns string
+	query   string
+}{
+	{
+		"reports",
+		`id, file_id, student_id, assignment_id, plagiarism_score, is_plagiarism, matched_file_id, matched_reference_id,
+		analysis_state, same_details, created_at`,
+		`SELECT r.id, r.file_id, COALESCE(f.student_id, ''), COALESCE(f.assignment_id, ''), r.plagiarism_score, r.is_plagiarism,
+		r.matched_file_id, r.matched_reference_id, r.analysis_state, r.same_details, r.created_at
+		FROM legacy.reports r LEFT JOIN legacy.files f ON f.id = r.file_id`,
+	},
+	{
+		"member_matches",
+		`id, report_id, member_path, matched_file_id, matched_member_path, score`,
+		`SELECT id, report_id, member_path, matched_file_id, matched_member_path, score FROM legacy.member_matches`,
+	},
+	{
+		"reference_files",
+		`id, corpus, assignment_id, source_path, file_path, created_at`,
+		`SELECT id, corpus, assignment_id, source_path, file_path, created_at FROM legacy.reference_files
+		WHERE id IN (SELECT MIN(id) FROM legacy.reference_files GROUP BY corpus, source_path)`,
+	},
+}
+
+// importLegacy переносит данные из старой общей БД и возвращает число строк по таблицам.
+func importLegacy(path string) (map[string]int64, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	// ATTACH действует на одно соединение, поэтому всё делается на нём
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	for _, t := range legacyTables {
+		var count int
+		if err := conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+t.table).Scan(&count); err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			return nil, fmt.Errorf("в БД сервиса анализа уже есть данные в таблице %s", t.table)
+		}
+	}
+	if _, err := conn.ExecContext(ctx, `ATTACH DATABASE ? AS legacy`, path); err != nil {
+		return nil, err
+	}
+	defer conn.ExecContext(ctx, `DETACH DATABASE legacy`)
+
+	var hasFiles int
+	err = conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM legacy.sqlite_master WHERE type = 'table' AND name = 'files'`).Scan(&hasFiles)
+	if err != nil {
+		return nil, err
+	}
+	if hasFiles == 0 {
+		return nil, errors.New("это не БД сервиса хранения: нет таблицы files")
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	imported := map[string]int64{}
+	for _, t := range legacyTables {
+		var exists int
+		err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM legacy.sqlite_master WHERE type = 'table' AND name = ?`, t.table).Scan(&exists)
+		if err != nil {
+			return nil, err
+		}
+		if exists == 0 {
+			continue
+		}
+		result, err := tx.ExecContext(ctx, `INSERT INTO `+t.table+` (`+t.columns+`) `+t.query)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", t.table, err)
+		}
+		imported[t.table], _ = result.RowsAffected()
+	}
+	return imported, tx.Commit()
+}
+
+// runImportLegacy — подкоманда import-legacy.
+func runImportLegacy(args []string) {
+	if len(args) != 1 {
+		fmt.Println("Использование: import-legacy <путь к старой files.db>")
+		os.Exit(2)
+	}
+	imported, err := importLegacy(args[0])
+	if err != nil {
+		fmt.Println("Ошибка переноса данных:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Перенесено: отчётов %d, совпадений файлов проектов %d, эталонов %d\n",
+		imported["reports"], imported["member_matches"], imported["reference_files"])
+}
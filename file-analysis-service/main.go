@@ -4,21 +4,27 @@ import (
 	"bytes"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	_ "github.com/glebarez/go-sqlite"
+
+	"file-analysis-service/similarity"
+	"shared/formats"
+	"shared/pagination"
 )
 
+// AnalysisRequest — запрос на анализ. Студента, задание и текст работы сервис берёт
+// у сервиса хранения по file_id.
 type AnalysisRequest struct {
-	FileID       int    `json:"file_id"`
-	FilePath     string `json:"file_path"`
-	StudentID    string `json:"student_id"`
-	AssignmentID string `json:"assignment_id"`
+	FileID  int      `json:"file_id"`
+	Corpora []string `json:"corpora,omitempty"`
 }
 
 type PlagiarismReport struct {
@@ -27,48 +33,75 @@ type PlagiarismReport struct {
 	PlagiarismScore float64 `json:"plagiarism_score"`
 	IsPlagiarism    bool    `json:"is_plagiarism"`
 	MatchedFileID   int     `json:"matched_file_id"`
-	AnalysisState   string  `json:"analysis_state"`
-	SameDetails     string  `json:"same_details"`
+	// MatchedReferenceID — ID эталонного решения, если наибольшее совпадение найдено в корпусе.
+	MatchedReferenceID int    `json:"matched_reference_id,omitempty"`
+	AnalysisState      string `json:"analysis_state"`
+	SameDetails        string `json:"same_details"`
+	// MemberMatches — совпадения по отдельным файлам, если работа загружена проектом.
+	MemberMatches []MemberMatch `json:"member_matches,omitempty"`
 }
 
 var db *sql.DB
 
+// algorithm — алгоритм сходства из реестра similarity, выбирается переменной
+// SIMILARITY_ALGORITHM. Его же по имени проверяет cmd/evaluate.
+var algorithm similarity.Func
+
 func init() {
 	var err error
-	db, err = sql.Open("sqlite", "/app/files.db")
+	path := analysisDBPath()
+	os.MkdirAll(filepath.Dir(path), 0755)
+	db, err = sql.Open("sqlite", path)
 	if err != nil {
 		panic("Ошибка подключения к БД: " + err.Error())
 	}
-	fmt.Println("file-analysis-service подключен к БД")
-	createReportsTable()
+	fmt.Println("file-analysis-service подключен к БД", path)
+	formats.Load()
+
+	name := os.Getenv("SIMILARITY_ALGORITHM")
+	if name == "" {
+		name = "words"
+	}
+	var ok bool
+	if algorithm, ok = similarity.Get(name); !ok {
+		panic(fmt.Sprintf("Алгоритм сходства %s не зарегистрирован. Доступны: %s", name, strings.Join(similarity.Names(), ", ")))
+	}
+	fmt.Println("Алгоритм сходства:", name)
 }
 
-func createReportsTable() {
-	query := `
-	CREATE TABLE IF NOT EXISTS reports (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		file_id INTEGER NOT NULL,
-		plagiarism_score INTEGER NOT NULL,
-		is_plagiarism BOOLEAN NOT NULL,
-		matched_file_id INTEGER NOT NULL,
-		analysis_state STRING NOT NULL,
-		same_details STRING NOT NULL,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	)
-	`
-	_, err := db.Exec(query)
-	if err != nil {
-		panic("Ошибка создания таблицы отчётов: " + err.Error())
+// analysisDBPath — файл БД сервиса анализа (ANALYSIS_DB_PATH). Таблица files живёт в БД
+// сервиса хранения, здесь только отчёты, совпадения файлов проектов и эталоны.
+func analysisDBPath() string {
+	if path := os.Getenv("ANALYSIS_DB_PATH"); path != "" {
+		return path
 	}
-	fmt.Println("Таблица для отчётов по плагиату готова к использованию")
+	return "/app/data/analysis.db"
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+	if _, err := migrateUp(0); err != nil {
+		panic("Ошибка миграции БД: " + err.Error())
+	}
+	fmt.Println("Схема БД актуальна")
+	if len(os.Args) > 1 && os.Args[1] == "import-corpus" {
+		runImportCorpus(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "import-legacy" {
+		runImportLegacy(os.Args[2:])
+		return
+	}
+	os.MkdirAll("/app/corpora", 0755)
 	http.HandleFunc("/health", healthHandler)
 	http.HandleFunc("/analyze", analyzeHandler)
 	http.HandleFunc("/reports", getAllReportsHandler)
 	http.HandleFunc("/reports/", getReportHandler)
 	http.HandleFunc("/wordCloud/", getWordCloudHandler)
+	http.HandleFunc("/corpora", corporaHandler)
 
 	fmt.Println("File Analysis Service запущен на http://localhost:8081")
 	http.ListenAndServe(":8081", nil)
@@ -83,6 +116,9 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// analyzeHandler анализирует работу, которую gateway уже поставил в очередь. Работу в другом
+// статусе (uploaded, completed) сервис хранения не переведёт в analyzing, и ответ будет 409:
+// в очередь работы ставит только gateway.
 func analyzeHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Only POST method is supported.", http.StatusMethodNotAllowed)
@@ -95,59 +131,94 @@ func analyzeHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, `Ошибка при парсинге JSON`, http.StatusBadRequest)
 		return
 	}
-	fmt.Printf("Анализ файла: %s (File ID: %d)\n", req.FilePath, req.FileID)
-	ext := filepath.Ext(req.FilePath)
-	supportedExts := map[string]bool{
-		".txt":  true,
-		".go":   true,
-		".py":   true,
-		".js":   true,
-		".java": true,
-		".cpp":  true,
-		".c":    true,
-		".h":    true,
-		".ts":   true,
-		".md":   true,
-	}
-	if !supportedExts[ext] {
-		fmt.Printf("Пропуск файла %s: неподдерживаемый формат %s\n", req.FilePath, ext)
+	fmt.Printf("Анализ файла (File ID: %d)\n", req.FileID)
+	if err := setFileStatus(req.FileID, statusAnalyzing); err != nil {
+		storingFailure(w, err)
+		return
+	}
+	sub, err := fetchSubmission(req.FileID)
+	if err != nil {
+		updateStatus(req.FileID, statusFailed)
+		storingFailure(w, err)
+		return
+	}
+	ext := filepath.Ext(sub.TextPath)
+	if !sub.isProject() && !formats.IsSourceFile(sub.TextPath) {
+		fmt.Printf("Пропуск файла %s: неподдерживаемый формат %s\n", sub.TextPath, ext)
 		report := PlagiarismReport{
 			FileID:          req.FileID,
 			AnalysisState:   "skipped",
 			PlagiarismScore: 0,
 			IsPlagiarism:    false,
-			SameDetails:     fmt.Sprintf("Формат %s не поддерживается. Разрешены: txt, go, py, java, cpp, c, h, js, ts, md", ext),
+			SameDetails:     fmt.Sprintf("Формат %s не поддерживается. Разрешены: %s", ext, formats.ExtensionList(true)),
 		}
-		SaveReport(req.FileID, 0, false, 0, "skipped because of incorrect extension")
+		SaveReport(sub, 0, false, 0, 0, "skipped because of incorrect extension")
+		updateStatus(req.FileID, statusSkipped)
 		json.NewEncoder(w).Encode(report)
 		return
 	}
-	newFileText, err := os.ReadFile(req.FilePath)
+	members, err := loadSubmission(sub)
 	if err != nil {
-		fmt.Printf("Ошибка чтения файла: %v/n", err)
+		fmt.Printf("Ошибка чтения файла: %v\n", err)
 		report := PlagiarismReport{
 			FileID:          req.FileID,
 			AnalysisState:   "error",
 			PlagiarismScore: 0,
 			IsPlagiarism:    false,
 		}
+		updateStatus(req.FileID, statusFailed)
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+	if state, reason := skipReason(sub, members); state != "" {
+		fmt.Printf("Пропуск файла %s: %s\n", sub.TextPath, reason)
+		report := saveSkippedReport(sub, state, reason)
+		updateStatus(req.FileID, statusSkipped)
 		json.NewEncoder(w).Encode(report)
 		return
 	}
-	newFileContent := string(newFileText)
-	fmt.Printf("Файл прочитан, размер файла: %d символов\n", len(newFileContent))
+	newFileContent := joinMembers(members)
+	fmt.Printf("Файл прочитан, файлов: %d, размер: %d символов\n", len(members), len(newFileContent))
 
-	plagiarismScore, matchedFileID := comparePlagiarism(newFileContent, req.StudentID, req.AssignmentID, req.FileID)
+	plagiarismScore, matchedFileID, memberMatches := comparePlagiarism(members, sub)
+	matchedReferenceID := 0
+	referenceScore, referenceID := compareWithCorpora(members, sub.AssignmentID, req.Corpora)
+	if referenceScore > plagiarismScore {
+		plagiarismScore = referenceScore
+		matchedFileID = 0
+		matchedReferenceID = referenceID
+	}
 	isPlagiarism := plagiarismScore > 0.5
 	fmt.Printf("Результат плагиата: %.2f%% \n ", plagiarismScore*100)
 
-	report := SaveReport(req.FileID, plagiarismScore, isPlagiarism, matchedFileID, "completed")
+	report := SaveReport(sub, plagiarismScore, isPlagiarism, matchedFileID, matchedReferenceID, "completed")
+	if sub.isProject() && report.ID != 0 {
+		saveMemberMatches(report.ID, memberMatches)
+		report.MemberMatches = memberMatches
+	}
+	if report.AnalysisState == "error" {
+		updateStatus(req.FileID, statusFailed)
+	} else {
+		updateStatus(req.FileID, statusCompleted)
+	}
 
 	fmt.Printf("Анализ завершен. Результат отправляем...\n")
 	json.NewEncoder(w).Encode(report)
 }
 
-func SaveReport(fileID int, score float64, isPlagiarism bool, matchedFileID int, status string) PlagiarismReport {
+// storingFailure отвечает на запрос анализа, когда сервис хранения отказал или недоступен:
+// его отказ (нет файла, работа уже в анализе) передаётся как есть.
+func storingFailure(w http.ResponseWriter, err error) {
+	var rejected *storingError
+	if errors.As(err, &rejected) {
+		http.Error(w, rejected.message, rejected.code)
+		return
+	}
+	fmt.Println("Сервис хранения недоступен:", err)
+	http.Error(w, `Сервис хранения недоступен`, http.StatusBadGateway)
+}
+
+func SaveReport(sub Submission, score float64, isPlagiarism bool, matchedFileID int, matchedReferenceID int, status string) PlagiarismReport {
 	isPlagiarismInt := 0
 	if isPlagiarism {
 		isPlagiarismInt = 1
@@ -155,19 +226,25 @@ func SaveReport(fileID int, score float64, isPlagiarism bool, matchedFileID int,
 	query := `
 	INSERT INTO reports (
 	    file_id,
+	    student_id,
+	    assignment_id,
     	plagiarism_score,
 	    is_plagiarism,
 	    matched_file_id,
+	    matched_reference_id,
         analysis_state,
         same_details
-	) VALUES (?, ?, ?, ?, ?, ?)
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 	details := fmt.Sprintf("Совпадение %.2f%% с File ID %d", score*100, matchedFileID)
-	result, err := db.Exec(query, fileID, score, isPlagiarismInt, matchedFileID, status, details)
+	if matchedReferenceID != 0 {
+		details = fmt.Sprintf("Совпадение %.2f%% с %s", score*100, referenceLabel(matchedReferenceID))
+	}
+	result, err := db.Exec(query, sub.ID, sub.StudentID, sub.AssignmentID, score, isPlagiarismInt, matchedFileID, matchedReferenceID, status, details)
 	if err != nil {
 		fmt.Println("Ошибка при создании отчёта")
 		return PlagiarismReport{
-			FileID:          fileID,
+			FileID:          sub.ID,
 			AnalysisState:   "error",
 			PlagiarismScore: 0,
 			IsPlagiarism:    false,
@@ -176,81 +253,114 @@ func SaveReport(fileID int, score float64, isPlagiarism bool, matchedFileID int,
 	}
 	reportID, _ := result.LastInsertId()
 	report := PlagiarismReport{
-		ID:              int(reportID),
-		FileID:          fileID,
-		PlagiarismScore: score,
-		IsPlagiarism:    isPlagiarism,
-		MatchedFileID:   matchedFileID,
-		AnalysisState:   "completed",
-		SameDetails:     details,
+		ID:                 int(reportID),
+		FileID:             sub.ID,
+		PlagiarismScore:    score,
+		IsPlagiarism:       isPlagiarism,
+		MatchedFileID:      matchedFileID,
+		MatchedReferenceID: matchedReferenceID,
+		AnalysisState:      status,
+		SameDetails:        details,
 	}
 	return report
 }
 
-func comparePlagiarism(newFileContent string, curStudentID string, curAssignmentID string, curFileID int) (float64, int) {
+// saveSkippedReport сохраняет отчёт о работе, которую не удалось проанализировать,
+// с понятным состоянием и причиной вместо нулевого процента совпадения.
+func saveSkippedReport(sub Submission, state string, details string) PlagiarismReport {
 	query := `
-	SELECT id, file_path, student_id 
-	FROM files 
-	WHERE id != ? AND student_id != ? AND assignment_id = ?
-	ORDER BY id ASC
+	INSERT INTO reports (file_id, student_id, assignment_id, plagiarism_score, is_plagiarism, matched_file_id, analysis_state, same_details)
+	VALUES (?, ?, ?, 0, 0, 0, ?, ?)
 	`
-	rows, err := db.Query(query, curFileID, curStudentID, curAssignmentID)
+	report := PlagiarismReport{
+		FileID:        sub.ID,
+		AnalysisState: state,
+		SameDetails:   details,
+	}
+	result, err := db.Exec(query, sub.ID, sub.StudentID, sub.AssignmentID, state, details)
 	if err != nil {
-		fmt.Println("Ошибка при запросе к БД", err)
-		return 0, 0
+		fmt.Println("Ошибка при создании отчёта")
+		return report
+	}
+	reportID, _ := result.LastInsertId()
+	report.ID = int(reportID)
+	return report
+}
+
+// skipReason возвращает состояние и причину, по которым работа не анализируется:
+// сервис хранения пометил её бинарной или документом без текста, в тексте встречаются
+// нулевые байты или в работе нет ни одного слова.
+func skipReason(sub Submission, members []similarity.Member) (string, string) {
+	reason := sub.ContentReason
+	if sub.ContentCheck == "binary" {
+		if reason == "" {
+			reason = "файл помечен сервисом хранения как бинарный"
+		}
+		return "skipped_binary", reason
+	}
+	if sub.ContentCheck == "no_text" {
+		if reason == "" {
+			reason = "в документе нет извлекаемого текста"
+		}
+		return "skipped_no_text", reason
+	}
+	for _, m := range members {
+		if strings.ContainsRune(m.Content, 0) {
+			return "skipped_binary", fmt.Sprintf("файл %s содержит нулевые байты", m.Path)
+		}
+	}
+	if strings.TrimSpace(joinMembers(members)) == "" {
+		return "skipped_no_text", "в работе нет текста для сравнения"
+	}
+	return "", ""
+}
+
+// comparePlagiarism сравнивает работу целиком с работами других студентов по этому заданию,
+// а также каждый файл работы с каждым файлом этих работ.
+func comparePlagiarism(members []similarity.Member, cur Submission) (float64, int, []MemberMatch) {
+	submissions, err := fetchSubmissions(cur.AssignmentID)
+	if err != nil {
+		fmt.Println("Ошибка при получении работ задания:", err)
+		return 0, 0, nil
 	}
-	defer rows.Close()
 
 	maxSimilarity := 0.0
 	matchedFileID := 0
-	for rows.Next() {
-		var fileID int
-		var filePath, studentID string
-
-		err = rows.Scan(&fileID, &filePath, &studentID)
-		if err != nil {
+	bestMembers := map[string]MemberMatch{}
+	for _, other := range submissions {
+		if other.ID == cur.ID || other.StudentID == cur.StudentID || other.ContentCheck != "text" {
 			continue
 		}
-		oldFileText, err := os.ReadFile(filePath)
+		fileID := other.ID
+		oldMembers, err := loadSubmission(other)
 		if err != nil {
-			fmt.Printf("Ошибка чтения файла %s: %v\n", filePath, err)
+			fmt.Printf("Ошибка чтения файла %d: %v\n", fileID, err)
 			continue
 		}
-		oldFileContent := string(oldFileText)
-		similarity := countSim(newFileContent, oldFileContent)
+		score := similarity.Compare(algorithm, members, oldMembers)
 
-		fmt.Printf("Сравнение с File ID %d: %.2f%% совпадения\n", fileID, similarity*100)
-		if similarity > maxSimilarity {
-			maxSimilarity = similarity
+		fmt.Printf("Сравнение с File ID %d: %.2f%% совпадения\n", fileID, score*100)
+		if score > maxSimilarity {
+			maxSimilarity = score
 			matchedFileID = fileID
 		}
-	}
-	fmt.Printf("Максимальное совпадение: %.2f%% (с File ID: %d)\n", maxSimilarity*100, matchedFileID)
-	return maxSimilarity, matchedFileID
-}
-
-func countSim(newFileContent string, oldFileContent string) float64 {
-	words1 := strings.Fields(strings.ToLower(newFileContent))
-	words2 := strings.Fields(strings.ToLower(oldFileContent))
-
-	if len(words1) == 0 || len(words2) == 0 {
-		return 0.0
-	}
-
-	matchCount := 0
-	for _, w1 := range words1 {
-		for _, w2 := range words2 {
-			if w1 == w2 {
-				matchCount++
-				break
+		for _, m := range members {
+			for _, old := range oldMembers {
+				memberScore := similarity.Compare(algorithm, []similarity.Member{m}, []similarity.Member{old})
+				if best, ok := bestMembers[m.Path]; ok && best.Score >= memberScore {
+					continue
+				}
+				bestMembers[m.Path] = MemberMatch{
+					MemberPath:        m.Path,
+					MatchedFileID:     fileID,
+					MatchedMemberPath: old.Path,
+					Score:             memberScore,
+				}
 			}
 		}
 	}
-	maxLen := len(words1)
-	if len(words2) > maxLen {
-		maxLen = len(words2)
-	}
-	return float64(matchCount) / float64(maxLen)
+	fmt.Printf("Максимальное совпадение: %.2f%% (с File ID: %d)\n", maxSimilarity*100, matchedFileID)
+	return maxSimilarity, matchedFileID, sortedMemberMatches(bestMembers)
 }
 
 func getReportHandler(w http.ResponseWriter, r *http.Request) {
@@ -262,7 +372,7 @@ func getReportHandler(w http.ResponseWriter, r *http.Request) {
 	reportID := r.URL.Path[len("/reports/"):]
 
 	query := `
-	SELECT id, file_id, plagiarism_score, is_plagiarism, matched_file_id, analysis_state, same_details
+	SELECT id, file_id, plagiarism_score, is_plagiarism, matched_file_id, matched_reference_id, analysis_state, same_details
 	FROM reports
 	WHERE id = ?
 	`
@@ -277,6 +387,7 @@ func getReportHandler(w http.ResponseWriter, r *http.Request) {
 		&report.PlagiarismScore,
 		&isPlagiarismInt,
 		&report.MatchedFileID,
+		&report.MatchedReferenceID,
 		&report.AnalysisState,
 		&report.SameDetails,
 	)
@@ -289,6 +400,7 @@ func getReportHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	report.IsPlagiarism = isPlagiarismInt == 1
+	report.MemberMatches = loadMemberMatches(report.ID)
 	json.NewEncoder(w).Encode(report)
 }
 
@@ -298,11 +410,71 @@ func getAllReportsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
+
+	q := r.URL.Query()
+	params, err := pagination.ParseParams(q, reportSortFields, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var filters pagination.Filters
+	if v := q.Get("file_id"); v != "" {
+		filters.Add("r.file_id = ?", v)
+	}
+	if v := q.Get("student_id"); v != "" {
+		filters.Add("r.student_id = ?", v)
+	}
+	if v := q.Get("assignment_id"); v != "" {
+		filters.Add("r.assignment_id = ?", v)
+	}
+	if v := q.Get("group_id"); v != "" {
+		// Состав групп знает сервис хранения
+		students, err := fetchGroupStudents(v)
+		if err != nil {
+			storingFailure(w, err)
+			return
+		}
+		ids, _ := json.Marshal(students)
+		filters.Add("r.student_id IN (SELECT value FROM json_each(?))", string(ids))
+	}
+	if v := q.Get("analysis_state"); v != "" {
+		filters.Add("r.analysis_state = ?", v)
+	}
+	if v := q.Get("is_plagiarism"); v != "" {
+		isPlagiarism, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, `is_plagiarism должен быть true или false`, http.StatusBadRequest)
+			return
+		}
+		filters.Add("r.is_plagiarism = ?", isPlagiarism)
+	}
+	for param, cond := range map[string]string{"min_score": "r.plagiarism_score >= ?", "max_score": "r.plagiarism_score <= ?"} {
+		if v := q.Get(param); v != "" {
+			score, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				http.Error(w, fmt.Sprintf(`%s должен быть числом`, param), http.StatusBadRequest)
+				return
+			}
+			filters.Add(cond, score)
+		}
+	}
+	if err := filters.AddTimeRange(q, "r.created_at", "created_from", "created_to"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	const from = ` FROM reports r`
+	var total int
+	err = db.QueryRow(`SELECT COUNT(*)`+from+filters.Where(), filters.Args...).Scan(&total)
+	if err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
+	filters.AddCursor(params, "r.id")
 	query := `
-	SELECT id, file_id, plagiarism_score, is_plagiarism, matched_file_id, analysis_state, same_details
-	FROM reports
-	`
-	rows, err := db.Query(query)
+	SELECT r.id, r.file_id, r.plagiarism_score, r.is_plagiarism, r.matched_file_id, r.matched_reference_id, r.analysis_state, r.same_details
+	` + from + filters.Where() + params.OrderBy("r.id") + ` LIMIT ?`
+	rows, err := db.Query(query, append(filters.Args, params.Limit+1)...)
 	if err != nil {
 		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
 		return
@@ -318,6 +490,7 @@ func getAllReportsHandler(w http.ResponseWriter, r *http.Request) {
 			&report.PlagiarismScore,
 			&isPlagiarismInt,
 			&report.MatchedFileID,
+			&report.MatchedReferenceID,
 			&report.AnalysisState,
 			&report.SameDetails,
 		)
@@ -327,7 +500,28 @@ func getAllReportsHandler(w http.ResponseWriter, r *http.Request) {
 		report.IsPlagiarism = isPlagiarismInt == 1
 		reports = append(reports, report)
 	}
-	json.NewEncoder(w).Encode(reports)
+	nextCursor := ""
+	if len(reports) > params.Limit {
+		reports = reports[:params.Limit]
+		nextCursor, err = params.NextCursor(db, "reports r", "r.id", int64(reports[len(reports)-1].ID))
+		if err != nil {
+			http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+			return
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"reports":     reports,
+		"total":       total,
+		"next_cursor": nextCursor,
+	})
+}
+
+// reportSortFields — поля, по которым можно сортировать список отчётов.
+var reportSortFields = map[string]string{
+	"id":               "r.id",
+	"created_at":       "r.created_at",
+	"file_id":          "r.file_id",
+	"plagiarism_score": "r.plagiarism_score",
 }
 
 func getWordCloudHandler(w http.ResponseWriter, r *http.Request) {
@@ -337,22 +531,23 @@ func getWordCloudHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	w.Header().Set("Content-Type", "image/png")
 	w.Header().Set("Content-Disposition", "attachment; filename=\"wordcloud.png\"")
-	fileID := r.URL.Path[len("/wordcloud/"):]
-	var filePath string
-	query := `
-	SELECT file_path FROM files WHERE id = ?
-	`
-	row := db.QueryRow(query, fileID)
-	err := row.Scan(&filePath)
-	if err == sql.ErrNoRows {
+	fileID, err := strconv.Atoi(r.URL.Path[len("/wordcloud/"):])
+	if err != nil {
+		http.Error(w, `Файл не найден`, http.StatusNotFound)
+		return
+	}
+	sub, err := fetchSubmission(fileID)
+	var rejected *storingError
+	if errors.As(err, &rejected) && rejected.code == http.StatusNotFound {
 		http.Error(w, `Файл не найден`, http.StatusNotFound)
 		return
 	}
 	if err != nil {
-		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		fmt.Println("Сервис хранения недоступен:", err)
+		http.Error(w, `Сервис хранения недоступен`, http.StatusBadGateway)
 		return
 	}
-	fileContent, err := os.ReadFile(filePath)
+	members, err := loadSubmission(sub)
 	if err != nil {
 		http.Error(w, `Ошибка чтения файла`, http.StatusInternalServerError)
 		return
@@ -367,7 +562,7 @@ func getWordCloudHandler(w http.ResponseWriter, r *http.Request) {
 		"scale":           "sqrt",
 		"removeStopwords": true,
 		"minWordLength":   3,
-		"text":            string(fileContent),
+		"text":            joinMembers(members),
 	}
 
 	jsonPayload, err := json.Marshal(payload)
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+
+	"shared/migrate"
+)
+
+// Схема БД задаётся миграциями из migrations/, встроенными в бинарник: NNNN_имя.up.sql
+// вносит изменение, NNNN_имя.down.sql откатывает его. Применённые версии записываются в
+// schema_migrations. При запуске сервис применяет недостающие миграции, а подкоманда
+// migrate позволяет управлять ими вручную:
+//
+//	file-analysis migrate up [версия]  — применить миграции (до версии включительно)
+//	file-analysis migrate down [N]     — откатить N последних миграций (по умолчанию одну)
+//	file-analysis migrate status       — какие миграции применены
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// legacyColumns — колонки, которые версии сервиса до миграций добавляли в существующие
+// таблицы при запуске. В БД такой версии их может не хватать, а миграция 0001 таблицу,
+// которая уже есть, не трогает.
+var legacyColumns = []migrate.Column{
+	{Table: "reports", Name: "matched_reference_id", Definition: "INTEGER NOT NULL DEFAULT 0"},
+	{Table: "reports", Name: "student_id", Definition: "TEXT NOT NULL DEFAULT ''"},
+	{Table: "reports", Name: "assignment_id", Definition: "TEXT NOT NULL DEFAULT ''"},
+}
+
+func migrator() *migrate.Migrator {
+	files, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		panic("Ошибка чтения миграций: " + err.Error())
+	}
+	return &migrate.Migrator{DB: db, Files: files, Legacy: legacyColumns}
+}
+
+// migrateUp применяет ещё не применённые миграции до версии target (0 — все).
+func migrateUp(target int) (int, error) {
+	return migrator().Up(target)
+}
+
+// runMigrate — подкоманда migrate up|down|status.
+func runMigrate(args []string) {
+	migrator().Run(args)
+}
@@ -0,0 +1,4 @@
+-- Удаляет все данные сервиса: откатывать только на пустой или ненужной БД.
+DROP TABLE IF EXISTS member_matches;
+DROP TABLE IF EXISTS reference_files;
+DROP TABLE IF EXISTS reports;
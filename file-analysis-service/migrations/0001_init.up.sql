@@ -0,0 +1,33 @@
+-- Схема, до которой сервис дорос до появления миграций. IF NOT EXISTS — потому что в БД,
+-- созданных старыми версиями сервиса, эти таблицы уже есть.
+CREATE TABLE IF NOT EXISTS reports (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	file_id INTEGER NOT NULL,
+	plagiarism_score INTEGER NOT NULL,
+	is_plagiarism BOOLEAN NOT NULL,
+	matched_file_id INTEGER NOT NULL,
+	analysis_state STRING NOT NULL,
+	same_details STRING NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	matched_reference_id INTEGER NOT NULL DEFAULT 0,
+	student_id TEXT NOT NULL DEFAULT '',
+	assignment_id TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS reference_files (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	corpus TEXT NOT NULL,
+	assignment_id TEXT NOT NULL DEFAULT '',
+	source_path TEXT NOT NULL,
+	file_path TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS member_matches (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	report_id INTEGER NOT NULL,
+	member_path TEXT NOT NULL,
+	matched_file_id INTEGER NOT NULL,
+	matched_member_path TEXT NOT NULL,
+	score REAL NOT NULL
+);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_reference_files_corpus;
+DROP INDEX IF EXISTS idx_member_matches_report_id;
+DROP INDEX IF EXISTS idx_reports_assignment_id;
+DROP INDEX IF EXISTS idx_reports_student_id;
+DROP INDEX IF EXISTS idx_reports_file_id;
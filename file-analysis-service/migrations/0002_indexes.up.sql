@@ -0,0 +1,7 @@
+-- Отчёты ищутся по работе (последний отчёт, повторный анализ) и фильтруются по студенту и
+-- заданию; совпадения файлов проектов — по отчёту, эталоны — по корпусу.
+CREATE INDEX idx_reports_file_id ON reports (file_id);
+CREATE INDEX idx_reports_student_id ON reports (student_id);
+CREATE INDEX idx_reports_assignment_id ON reports (assignment_id);
+CREATE INDEX idx_member_matches_report_id ON member_matches (report_id);
+CREATE INDEX idx_reference_files_corpus ON reference_files (corpus);
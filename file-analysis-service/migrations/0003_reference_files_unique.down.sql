@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_reference_files_corpus_source_path;
+CREATE INDEX IF NOT EXISTS idx_reference_files_corpus ON reference_files (corpus);
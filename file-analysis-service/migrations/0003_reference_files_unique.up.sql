@@ -0,0 +1,6 @@
+-- Повторный импорт корпуса раньше добавлял эталоны заново: оставляем по одному эталону на
+-- путь (самый ранний — на него могут ссылаться отчёты) и запрещаем дубликаты. Индекс по
+-- (corpus, source_path) заменяет индекс по corpus.
+DELETE FROM reference_files WHERE id NOT IN (SELECT MIN(id) FROM reference_files GROUP BY corpus, source_path);
+DROP INDEX IF EXISTS idx_reference_files_corpus;
+CREATE UNIQUE INDEX idx_reference_files_corpus_source_path ON reference_files (corpus, source_path);
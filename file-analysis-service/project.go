@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"file-analysis-service/similarity"
+	"shared/formats"
+)
+
+// MemberMatch описывает, с каким файлом другой работы сильнее всего совпал файл проекта.
+type MemberMatch struct {
+	MemberPath        string  `json:"member_path"`
+	MatchedFileID     int     `json:"matched_file_id"`
+	MatchedMemberPath string  `json:"matched_member_path"`
+	Score             float64 `json:"score"`
+}
+
+// loadSubmission получает текст работы из сервиса хранения: файл целиком или все
+// поддерживаемые файлы проекта.
+func loadSubmission(s Submission) ([]similarity.Member, error) {
+	if !s.isProject() {
+		content, err := fetchText(s.ID, "")
+		if err != nil {
+			return nil, err
+		}
+		return []similarity.Member{similarity.ReadMember(content, path.Base(s.TextPath))}, nil
+	}
+	var members []similarity.Member
+	for _, member := range s.Members {
+		if !formats.IsSourceFile(member) {
+			continue
+		}
+		content, err := fetchText(s.ID, member)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, similarity.ReadMember(content, member))
+	}
+	return members, nil
+}
+
+func joinMembers(members []similarity.Member) string {
+	parts := make([]string, len(members))
+	for i, m := range members {
+		parts[i] = m.Content
+	}
+	return strings.Join(parts, "\n")
+}
+
+func saveMemberMatches(reportID int, matches []MemberMatch) {
+	for _, m := range matches {
+		query := `
+		INSERT INTO member_matches (report_id, member_path, matched_file_id, matched_member_path, score)
+		VALUES (?, ?, ?, ?, ?)
+		`
+		_, err := db.Exec(query, reportID, m.MemberPath, m.MatchedFileID, m.MatchedMemberPath, m.Score)
+		if err != nil {
+			fmt.Println("Ошибка при сохранении совпадения файла проекта:", err)
+		}
+	}
+}
+
+func loadMemberMatches(reportID int) []MemberMatch {
+	query := `
+	SELECT member_path, matched_file_id, matched_member_path, score
+	FROM member_matches
+	WHERE report_id = ?
+	ORDER BY id ASC
+	`
+	rows, err := db.Query(query, reportID)
+	if err != nil {
+		fmt.Println("Ошибка при запросе к БД", err)
+		return nil
+	}
+	defer rows.Close()
+	var matches []MemberMatch
+	for rows.Next() {
+		var m MemberMatch
+		if err := rows.Scan(&m.MemberPath, &m.MatchedFileID, &m.MatchedMemberPath, &m.Score); err != nil {
+			continue
+		}
+		matches = append(matches, m)
+	}
+	return matches
+}
+
+func sortedMemberMatches(best map[string]MemberMatch) []MemberMatch {
+	matches := make([]MemberMatch, 0, len(best))
+	for _, m := range best {
+		matches = append(matches, m)
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].MemberPath < matches[j].MemberPath })
+	return matches
+}
@@ -0,0 +1,62 @@
+package similarity
+
+import (
+	"sort"
+	"strings"
+)
+
+// Func возвращает коэффициент сходства двух текстов в диапазоне от 0 до 1.
+type Func func(newContent string, oldContent string) float64
+
+var registry = map[string]Func{}
+
+// Register добавляет алгоритм в реестр под указанным именем.
+func Register(name string, fn Func) {
+	registry[name] = fn
+}
+
+// Get возвращает алгоритм по имени.
+func Get(name string) (Func, bool) {
+	fn, ok := registry[name]
+	return fn, ok
+}
+
+// Names возвращает отсортированный список зарегистрированных алгоритмов.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	Register("words", Words)
+}
+
+// Words считает долю слов нового текста, встречающихся в старом,
+// относительно длины большего из двух текстов.
+func Words(newContent string, oldContent string) float64 {
+	words1 := strings.Fields(strings.ToLower(newContent))
+	words2 := strings.Fields(strings.ToLower(oldContent))
+
+	if len(words1) == 0 || len(words2) == 0 {
+		return 0.0
+	}
+
+	matchCount := 0
+	for _, w1 := range words1 {
+		for _, w2 := range words2 {
+			if w1 == w2 {
+				matchCount++
+				break
+			}
+		}
+	}
+	maxLen := len(words1)
+	if len(words2) > maxLen {
+		maxLen = len(words2)
+	}
+	return float64(matchCount) / float64(maxLen)
+}
@@ -0,0 +1,103 @@
+package similarity
+
+import (
+	"math"
+	"os"
+	"sort"
+	"testing"
+
+	"shared/formats"
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv("FORMATS_CONFIG") == "" {
+		os.Setenv("FORMATS_CONFIG", "../../formats.json")
+	}
+	formats.Load()
+	os.Exit(m.Run())
+}
+
+func TestRegistry(t *testing.T) {
+	if _, ok := Get("words"); !ok {
+		t.Fatal("алгоритм words не зарегистрирован")
+	}
+	if _, ok := Get("missing"); ok {
+		t.Fatal("Get нашёл незарегистрированный алгоритм")
+	}
+
+	Register("test-constant", func(string, string) float64 { return 0.5 })
+	defer delete(registry, "test-constant")
+	fn, ok := Get("test-constant")
+	if !ok || fn("a", "b") != 0.5 {
+		t.Fatal("Get не вернул зарегистрированный алгоритм")
+	}
+
+	names := Names()
+	if !sort.StringsAreSorted(names) {
+		t.Fatalf("Names не отсортирован: %v", names)
+	}
+	if len(names) != len(registry) {
+		t.Fatalf("Names вернул %d алгоритмов, зарегистрировано %d", len(names), len(registry))
+	}
+}
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		name     string
+		new, old string
+		want     float64
+	}{
+		{"одинаковые", "a b c", "a b c", 1},
+		{"регистр не важен", "Print X", "print x", 1},
+		{"не пересекаются", "a b", "c d", 0},
+		{"пустой новый", "", "a b", 0},
+		{"пустой старый", "a b", "  ", 0},
+		{"делится на длину большего", "a b", "a b c d", 0.5},
+		{"повторы считаются", "a a", "a", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Words(tt.new, tt.old); math.Abs(got-tt.want) > 1e-9 {
+				t.Fatalf("Words(%q, %q) = %v, want %v", tt.new, tt.old, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	member := func(path string, content string) []Member {
+		return []Member{ReadMember([]byte(content), path)}
+	}
+	notebook := `{"cells": [
+		{"cell_type": "code", "source": ["%matplotlib inline\n", "x = total + 1 # комментарий"]},
+		{"cell_type": "markdown", "source": "считаем сумму"}
+	]}`
+	tests := []struct {
+		name     string
+		new, old []Member
+		want     float64
+	}{
+		{"комментарии в коде не учитываются",
+			member("a.py", "# списано\nx = total + 1\n"), member("b.py", "x = total + 1  # своё\n"), 1},
+		{"блочные комментарии",
+			member("a.go", "/* автор */ x := y"), member("b.go", "x := y"), 1},
+		{"в тексте комментариев нет",
+			member("a.txt", "# заголовок текст"), member("b.txt", "текст"), 1.0 / 3},
+		// Код блокнота совпал полностью (5 слов), markdown сравнивать не с чем (2 слова)
+		{"блокнот: магические команды и комментарии отброшены",
+			member("a.ipynb", notebook), member("b.py", "x = total + 1"), 5.0 / 7},
+		{"пустая работа", member("a.py", "# только комментарий"), member("b.py", "x = 1"), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Compare(Words, tt.new, tt.old); math.Abs(got-tt.want) > 1e-9 {
+				t.Fatalf("Compare = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	constant := func(string, string) float64 { return 0.25 }
+	if got := Compare(constant, member("a.py", "x = 1"), member("b.py", "y = 2")); got != 0.25 {
+		t.Fatalf("Compare не использовал переданный алгоритм: %v", got)
+	}
+}
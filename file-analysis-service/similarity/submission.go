@@ -0,0 +1,196 @@
+package similarity
+
+import (
+	"fmt"
+	"strings"
+
+	"shared/formats"
+	"shared/notebook"
+)
+
+// Member — один файл работы. Обычная работа состоит из одного файла,
+// проект, загруженный архивом, — из всех распакованных файлов.
+type Member struct {
+	Path    string
+	Content string
+	// Для блокнотов Jupyter: Content — текст всех ячеек, Code и Markdown — ячейки по типам.
+	Notebook bool
+	Code     string
+	Markdown string
+}
+
+// ReadMember разбирает файл работы: блокноты Jupyter делятся на ячейки кода и markdown.
+func ReadMember(content []byte, rel string) Member {
+	member := Member{Path: rel, Content: string(content)}
+	if isNotebook(rel) {
+		code, markdown, err := parseNotebook(content)
+		if err != nil {
+			fmt.Printf("Блокнот %s не разобран, сравниваем как текст: %v\n", rel, err)
+			return member
+		}
+		member.Notebook = true
+		member.Code = code
+		member.Markdown = markdown
+		member.Content = code + "\n" + markdown
+	}
+	return member
+}
+
+// parseNotebook возвращает код и markdown ячеек блокнота отдельно.
+func parseNotebook(data []byte) (string, string, error) {
+	cells, err := notebook.Parse(data)
+	if err != nil {
+		return "", "", err
+	}
+	var code, markdown []string
+	for _, cell := range cells {
+		switch cell.Type {
+		case "code":
+			code = append(code, cell.Source)
+		case "markdown":
+			markdown = append(markdown, cell.Source)
+		}
+	}
+	return strings.Join(code, "\n"), strings.Join(markdown, "\n"), nil
+}
+
+func isNotebook(path string) bool {
+	return formats.TokenizerFor(path) == "notebook"
+}
+
+// splitParts делит работу на код и текст по токенизатору из реестра форматов: ячейки
+// блокнотов разбираются по типу, из кода убираются комментарии его языка.
+func splitParts(members []Member) (string, string) {
+	var code, text []string
+	for _, m := range members {
+		switch {
+		case m.Notebook:
+			code = append(code, normalizeNotebookCode(m.Code, commentsFor(m.Path)))
+			text = append(text, m.Markdown)
+		case formats.TokenizerFor(m.Path) == "text":
+			text = append(text, m.Content)
+		default:
+			code = append(code, stripComments(m.Content, commentsFor(m.Path)))
+		}
+	}
+	return strings.Join(code, "\n"), strings.Join(text, "\n")
+}
+
+func commentsFor(path string) *formats.CommentSyntax {
+	if f := formats.For(path); f != nil {
+		return f.Comments
+	}
+	return nil
+}
+
+// Compare считает сходство двух работ алгоритмом fn по токенизаторам из реестра форматов: код
+// (в том числе ячейки блокнотов) сравнивается с кодом без комментариев, markdown и текст — с
+// текстом как есть, и оценки взвешиваются по объёму новой работы. Так работы сравнивает
+// сервис анализа, и так же их оценивает cmd/evaluate.
+func Compare(fn Func, newMembers []Member, oldMembers []Member) float64 {
+	newCode, newText := splitParts(newMembers)
+	oldCode, oldText := splitParts(oldMembers)
+	codeWords := len(strings.Fields(newCode))
+	textWords := len(strings.Fields(newText))
+	if codeWords+textWords == 0 {
+		return 0
+	}
+	codeScore := fn(newCode, oldCode)
+	textScore := fn(newText, oldText)
+	return (codeScore*float64(codeWords) + textScore*float64(textWords)) / float64(codeWords+textWords)
+}
+
+// normalizeNotebookCode убирает из кода комментарии и магические команды Jupyter (%matplotlib, !pip),
+// которые не влияют на решение и легко меняются при списывании.
+func normalizeNotebookCode(code string, comments *formats.CommentSyntax) string {
+	lines := strings.Split(code, "\n")
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "%") || strings.HasPrefix(trimmed, "!") {
+			continue
+		}
+		out = append(out, line)
+	}
+	return stripComments(strings.Join(out, "\n"), comments)
+}
+
+// stripComments убирает строчные и блочные комментарии по синтаксису языка,
+// не трогая содержимое строковых литералов. Пустые строки тоже выбрасываются.
+func stripComments(code string, comments *formats.CommentSyntax) string {
+	if comments == nil {
+		return code
+	}
+	var sb strings.Builder
+	var quote byte
+	blockEnd := ""
+	for i := 0; i < len(code); i++ {
+		c := code[i]
+		rest := code[i:]
+		switch {
+		case blockEnd != "":
+			if strings.HasPrefix(rest, blockEnd) {
+				i += len(blockEnd) - 1
+				blockEnd = ""
+			} else if c == '\n' {
+				sb.WriteByte(c)
+			}
+			continue
+		case quote != 0:
+			sb.WriteByte(c)
+			if c == '\\' && i+1 < len(code) {
+				i++
+				sb.WriteByte(code[i])
+			} else if c == quote || (c == '\n' && quote != '`') {
+				quote = 0
+			}
+			continue
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+			sb.WriteByte(c)
+			continue
+		}
+		if commentStart(rest, comments.Line) {
+			for i < len(code) && code[i] != '\n' {
+				i++
+			}
+			if i < len(code) {
+				sb.WriteByte('\n')
+			}
+			continue
+		}
+		if start, end := blockComment(rest, comments.Block); start != "" {
+			i += len(start) - 1
+			blockEnd = end
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	lines := strings.Split(sb.String(), "\n")
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			out = append(out, strings.TrimRight(line, " \t"))
+		}
+	}
+	return strings.Join(out, "\n")
+}
+
+func commentStart(s string, markers []string) bool {
+	for _, m := range markers {
+		if m != "" && strings.HasPrefix(s, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// blockComment возвращает маркеры блочного комментария, с которого начинается s.
+func blockComment(s string, blocks [][2]string) (string, string) {
+	for _, b := range blocks {
+		if b[0] != "" && b[1] != "" && strings.HasPrefix(s, b[0]) {
+			return b[0], b[1]
+		}
+	}
+	return "", ""
+}
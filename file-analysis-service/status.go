@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Статусы работы ведёт сервис хранения. В очередь работу ставит gateway, а этот сервис
+// переводит её в analyzing при получении запроса, затем в completed, failed или skipped.
+const (
+	statusAnalyzing = "analyzing"
+	statusCompleted = "completed"
+	statusFailed    = "failed"
+	statusSkipped   = "skipped"
+)
+
+func setFileStatus(fileID int, status string) error {
+	body, _ := json.Marshal(map[string]string{"status": status})
+	url := fmt.Sprintf("%s/internal/files/%d/status", storingServiceURL(), fileID)
+	resp, err := storingClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(resp.Body)
+		return &storingError{code: resp.StatusCode, message: strings.TrimSpace(string(message))}
+	}
+	return nil
+}
+
+// updateStatus переводит работу в следующий статус анализа. Ошибка только пишется в лог,
+// чтобы сбой сервиса хранения не терял уже сохранённый отчёт.
+func updateStatus(fileID int, status string) {
+	if err := setFileStatus(fileID, status); err != nil {
+		fmt.Printf("Не удалось перевести файл %d в статус %s: %v\n", fileID, status, err)
+	}
+}
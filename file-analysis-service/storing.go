@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Работы и их текст сервис анализа получает только через внутренний API сервиса хранения:
+// таблица files и хранилище блобов принадлежат ему, а у сервиса анализа своя БД с отчётами.
+
+var storingClient = &http.Client{Timeout: time.Minute}
+
+func storingServiceURL() string {
+	if url := os.Getenv("FILE_STORING_URL"); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	return "http://file-storing-service:8082"
+}
+
+// storingError — сервис хранения ответил ошибкой (нет файла, запрещённый переход статуса).
+type storingError struct {
+	code    int
+	message string
+}
+
+func (e *storingError) Error() string {
+	return fmt.Sprintf("%d: %s", e.code, e.message)
+}
+
+// Submission — сведения о работе из сервиса хранения.
+type Submission struct {
+	ID            int      `json:"id"`
+	StudentID     string   `json:"student_id"`
+	AssignmentID  string   `json:"assignment_id"`
+	Filename      string   `json:"filename"`
+	TextPath      string   `json:"text_path"`
+	ContentCheck  string   `json:"content_check"`
+	ContentReason string   `json:"content_reason"`
+	Members       []string `json:"members"`
+}
+
+func (s Submission) isProject() bool {
+	return len(s.Members) > 0
+}
+
+// storingGet выполняет GET к сервису хранения; ответ не 200 превращается в storingError.
+func storingGet(path string) (*http.Response, error) {
+	resp, err := storingClient.Get(storingServiceURL() + path)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, &storingError{code: resp.StatusCode, message: strings.TrimSpace(string(message))}
+	}
+	return resp, nil
+}
+
+func fetchSubmission(fileID int) (Submission, error) {
+	var s Submission
+	resp, err := storingGet(fmt.Sprintf("/internal/files/%d", fileID))
+	if err != nil {
+		return s, err
+	}
+	defer resp.Body.Close()
+	err = json.NewDecoder(resp.Body).Decode(&s)
+	return s, err
+}
+
+// fetchSubmissions возвращает текущие версии сдач задания (итоговые или последние) в порядке
+// загрузки: черновики, которые студент заменил, с работой не сравниваются.
+func fetchSubmissions(assignmentID string) ([]Submission, error) {
+	resp, err := storingGet("/internal/submissions?current=true&assignment_id=" + url.QueryEscape(assignmentID))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Submissions []Submission `json:"submissions"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	return result.Submissions, err
+}
+
+// fetchGroupStudents возвращает id студентов группы.
+func fetchGroupStudents(groupID string) ([]string, error) {
+	resp, err := storingGet("/internal/students?group_id=" + url.QueryEscape(groupID))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Students []string `json:"students"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	return result.Students, err
+}
+
+// fetchText возвращает UTF-8 текст работы, а для проекта — текст файла member.
+func fetchText(fileID int, member string) ([]byte, error) {
+	path := fmt.Sprintf("/internal/files/%d/text", fileID)
+	if member != "" {
+		path += "?member=" + url.QueryEscape(member)
+	}
+	resp, err := storingGet(path)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
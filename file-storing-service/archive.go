@@ -0,0 +1,223 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"shared/formats"
+)
+
+// archiveLimits ограничивают распаковку архивов, чтобы zip-бомба
+// или архив с миллионом файлов не заполнили диск сервера. Архивы внутри архива
+// не распаковываются вовсе, а отклоняются как неподдерживаемый формат, поэтому
+// MaxDepth ограничивает только вложенность каталогов.
+type archiveLimits struct {
+	MaxTotalSize int64   // суммарный размер распакованных файлов
+	MaxFiles     int     // количество записей в архиве
+	MaxDepth     int     // вложенность каталогов внутри архива
+	MaxRatio     float64 // степень сжатия (распакованный размер / сжатый)
+}
+
+var limits = archiveLimits{
+	MaxTotalSize: envInt64("ARCHIVE_MAX_TOTAL_SIZE", 50<<20),
+	MaxFiles:     int(envInt64("ARCHIVE_MAX_FILES", 500)),
+	MaxDepth:     int(envInt64("ARCHIVE_MAX_DEPTH", 10)),
+	MaxRatio:     float64(envInt64("ARCHIVE_MAX_RATIO", 100)),
+}
+
+// ratioCheckThreshold — степень сжатия проверяется только для больших данных:
+// маленькие текстовые файлы из повторяющихся строк могут сжиматься очень сильно.
+const ratioCheckThreshold = 1 << 20
+
+// errArchiveLimit — архив целиком отклонён из-за превышения лимитов.
+var errArchiveLimit = errors.New("превышены лимиты архива")
+
+// RejectedMember — файл архива, который не был распакован, и причина.
+type RejectedMember struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+type extractResult struct {
+	Members  []string
+	Rejected []RejectedMember
+}
+
+func envInt64(name string, def int64) int64 {
+	value, err := strconv.ParseInt(os.Getenv(name), 10, 64)
+	if err != nil || value <= 0 {
+		return def
+	}
+	return value
+}
+
+// extractArchive распаковывает проект в каталог dir. Небезопасные и неподдерживаемые файлы
+// пропускаются и попадают в Rejected, а при превышении лимитов возвращается errArchiveLimit.
+// kind — способ извлечения из реестра форматов: zip или tar.gz.
+func extractArchive(r io.ReaderAt, size int64, kind string, dir string) (extractResult, error) {
+	e := &extractor{dir: dir, archiveSize: size, seen: map[string]bool{}}
+	var err error
+	if kind == "zip" {
+		err = e.extractZip(r, size)
+	} else {
+		err = e.extractTarGz(io.NewSectionReader(r, 0, size))
+	}
+	return e.result, err
+}
+
+type extractor struct {
+	dir         string
+	archiveSize int64
+	written     int64
+	entries     int
+	// seen — пути уже распакованных файлов: запись с тем же путём перезаписала бы
+	// первый файл, а в submission_files попали бы две строки
+	seen   map[string]bool
+	result extractResult
+}
+
+func (e *extractor) extractZip(r io.ReaderAt, size int64) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if err := e.countEntry(); err != nil {
+			return err
+		}
+		if f.Mode()&os.ModeSymlink != 0 || !f.Mode().IsRegular() {
+			e.reject(f.Name, "символические ссылки и специальные файлы не поддерживаются")
+			continue
+		}
+		if f.UncompressedSize64 > ratioCheckThreshold && f.CompressedSize64 > 0 && float64(f.UncompressedSize64)/float64(f.CompressedSize64) > limits.MaxRatio {
+			return fmt.Errorf("%w: файл %s сжат более чем в %.0f раз", errArchiveLimit, f.Name, limits.MaxRatio)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = e.writeMember(f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *extractor) extractTarGz(r io.Reader) error {
+	gzr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gzr.Close()
+	tr := tar.NewReader(gzr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag == tar.TypeDir {
+			continue
+		}
+		if err := e.countEntry(); err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			e.reject(header.Name, "символические ссылки и специальные файлы не поддерживаются")
+			continue
+		}
+		if err := e.writeMember(header.Name, tr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *extractor) countEntry() error {
+	e.entries++
+	if e.entries > limits.MaxFiles {
+		return fmt.Errorf("%w: больше %d файлов", errArchiveLimit, limits.MaxFiles)
+	}
+	return nil
+}
+
+func (e *extractor) reject(name string, reason string) {
+	fmt.Printf("Пропуск файла из архива %s: %s\n", name, reason)
+	e.result.Rejected = append(e.result.Rejected, RejectedMember{Path: name, Reason: reason})
+}
+
+// writeMember проверяет путь и содержимое файла из архива и сохраняет его в каталог проекта.
+func (e *extractor) writeMember(name string, content io.Reader) error {
+	slashed := strings.ReplaceAll(name, `\`, "/")
+	if strings.HasPrefix(slashed, "/") || filepath.IsAbs(name) || filepath.VolumeName(name) != "" {
+		e.reject(name, "абсолютный путь")
+		return nil
+	}
+	for _, part := range strings.Split(slashed, "/") {
+		if part == ".." {
+			e.reject(name, "путь содержит ..")
+			return nil
+		}
+	}
+	rel := filepath.Clean(filepath.FromSlash(slashed))
+	if strings.Count(filepath.ToSlash(rel), "/") > limits.MaxDepth {
+		e.reject(name, fmt.Sprintf("вложенность каталогов больше %d", limits.MaxDepth))
+		return nil
+	}
+	if !formats.IsSourceFile(rel) {
+		e.reject(name, "неподдерживаемый формат")
+		return nil
+	}
+	if e.seen[rel] {
+		e.reject(name, "файл с таким путём уже есть в архиве")
+		return nil
+	}
+
+	remaining := limits.MaxTotalSize - e.written
+	br := bufio.NewReaderSize(io.LimitReader(content, remaining+1), sniffSize)
+	head, _ := br.Peek(sniffSize)
+	if reason := sniffContent(head); reason != "" {
+		e.reject(name, reason)
+		return nil
+	}
+
+	target := filepath.Join(e.dir, rel)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	dst, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	n, err := io.Copy(dst, br)
+	if err != nil {
+		return err
+	}
+	e.written += n
+	if e.written > limits.MaxTotalSize {
+		return fmt.Errorf("%w: распакованный размер больше %d байт", errArchiveLimit, limits.MaxTotalSize)
+	}
+	if e.written > ratioCheckThreshold && e.archiveSize > 0 && float64(e.written)/float64(e.archiveSize) > limits.MaxRatio {
+		return fmt.Errorf("%w: архив сжат более чем в %.0f раз", errArchiveLimit, limits.MaxRatio)
+	}
+	e.seen[rel] = true
+	e.result.Members = append(e.result.Members, filepath.ToSlash(rel))
+	return nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	loadFormats()
+	os.Exit(m.Run())
+}
+
+// archiveEntry — запись тестового архива. Пустой mode — обычный файл.
+type archiveEntry struct {
+	name    string
+	content string
+	mode    os.FileMode
+}
+
+func buildZip(t *testing.T, entries ...archiveEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		header := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		header.SetMode(0644)
+		if e.mode != 0 {
+			header.SetMode(e.mode)
+		}
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(e.content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func buildTarGz(t *testing.T, entries ...archiveEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.content)), Typeflag: tar.TypeReg}
+		if e.mode&os.ModeSymlink != 0 {
+			header.Typeflag, header.Linkname, header.Size = tar.TypeSymlink, e.content, 0
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			tw.Write([]byte(e.content))
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	gzw.Close()
+	return buf.Bytes()
+}
+
+// setArchiveLimits подменяет лимиты на время теста.
+func setArchiveLimits(t *testing.T, l archiveLimits) {
+	t.Helper()
+	saved := limits
+	limits = l
+	t.Cleanup(func() { limits = saved })
+}
+
+var testArchiveLimits = archiveLimits{MaxTotalSize: 4 << 20, MaxFiles: 10, MaxDepth: 3, MaxRatio: 100}
+
+func extractTestArchive(t *testing.T, kind string, data []byte) (extractResult, string, error) {
+	t.Helper()
+	dir := t.TempDir()
+	result, err := extractArchive(bytes.NewReader(data), int64(len(data)), kind, dir)
+	return result, dir, err
+}
+
+func rejectedPaths(result extractResult) []string {
+	var paths []string
+	for _, r := range result.Rejected {
+		paths = append(paths, r.Path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestExtractArchiveRejectsUnsafeMembers(t *testing.T) {
+	setArchiveLimits(t, archiveLimits{MaxTotalSize: 4 << 20, MaxFiles: 20, MaxDepth: 3, MaxRatio: 100})
+	entries := []archiveEntry{
+		{name: "main.py", content: "print(1)\n"},
+		{name: "lib/util.py", content: "x = 1\n"},
+		{name: "../escape.py", content: "evil\n"},
+		{name: "lib/../../escape2.py", content: "evil\n"},
+		{name: "/etc/cron.py", content: "evil\n"},
+		{name: `\abs.py`, content: "evil\n"},
+		{name: "link.py", content: "/etc/passwd", mode: os.ModeSymlink | 0777},
+		{name: "a/b/c/d/deep.py", content: "x = 1\n"},
+		{name: "tool.exe", content: "MZ"},
+		{name: "nested.zip", content: "PK\x03\x04"},
+		{name: "binary.py", content: "\x00\x01\x02\x03"},
+	}
+	want := []string{"../escape.py", "/etc/cron.py", `\abs.py`, "a/b/c/d/deep.py", "binary.py", "lib/../../escape2.py", "link.py", "nested.zip", "tool.exe"}
+	for _, kind := range []string{"zip", "tar.gz"} {
+		data := buildZip(t, entries...)
+		if kind == "tar.gz" {
+			data = buildTarGz(t, entries...)
+		}
+		result, dir, err := extractTestArchive(t, kind, data)
+		if err != nil {
+			t.Fatalf("%s: %v", kind, err)
+		}
+		if strings.Join(result.Members, ",") != "main.py,lib/util.py" {
+			t.Errorf("%s: распакованы %v", kind, result.Members)
+		}
+		if got := rejectedPaths(result); strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("%s: отклонены %v, ожидалось %v", kind, got, want)
+		}
+		// Ничего не записано за пределами каталога проекта
+		parent := filepath.Dir(dir)
+		for _, name := range []string{"escape.py", "escape2.py"} {
+			if _, err := os.Stat(filepath.Join(parent, name)); err == nil {
+				t.Errorf("%s: файл %s записан вне каталога проекта", kind, name)
+			}
+		}
+		if _, err := os.Lstat(filepath.Join(dir, "link.py")); err == nil {
+			t.Errorf("%s: символическая ссылка распакована", kind)
+		}
+	}
+}
+
+func TestExtractArchiveLimits(t *testing.T) {
+	setArchiveLimits(t, testArchiveLimits)
+	// Повторяющийся текст сжимается примерно в тысячу раз: это и есть zip-бомба
+	bomb := strings.Repeat("print('spam')\n", (2<<20)/14)
+	many := make([]archiveEntry, 11)
+	for i := range many {
+		many[i] = archiveEntry{name: filepath.Join("src", string(rune('a'+i))+".py"), content: "x = 1\n"}
+	}
+	large := []archiveEntry{
+		{name: "a.py", content: strings.Repeat("a = 1 # x\n", (3<<20)/10)},
+		{name: "b.py", content: strings.Repeat("b = 2 # y\n", (3<<20)/10)},
+	}
+	cases := []struct {
+		name    string
+		limits  archiveLimits
+		entries []archiveEntry
+		want    string
+	}{
+		{"количество файлов", testArchiveLimits, many, "больше 10 файлов"},
+		{"степень сжатия", testArchiveLimits, []archiveEntry{{name: "bomb.py", content: bomb}}, "сжат более чем в 100 раз"},
+		{"распакованный размер", archiveLimits{MaxTotalSize: 4 << 20, MaxFiles: 10, MaxDepth: 3, MaxRatio: 1 << 20}, large, "распакованный размер больше"},
+	}
+	for _, c := range cases {
+		for _, kind := range []string{"zip", "tar.gz"} {
+			limits = c.limits
+			data := buildZip(t, c.entries...)
+			if kind == "tar.gz" {
+				data = buildTarGz(t, c.entries...)
+			}
+			_, _, err := extractTestArchive(t, kind, data)
+			if !errors.Is(err, errArchiveLimit) || !strings.Contains(err.Error(), c.want) {
+				t.Errorf("%s, %s: ошибка %v, ожидалось %q", c.name, kind, err, c.want)
+			}
+		}
+	}
+}
+
+func TestExtractArchiveDepthLimit(t *testing.T) {
+	setArchiveLimits(t, testArchiveLimits)
+	data := buildZip(t,
+		archiveEntry{name: "a/b/c/ok.py", content: "x = 1\n"},
+		archiveEntry{name: "a/b/c/d/too-deep.py", content: "x = 1\n"},
+	)
+	result, _, err := extractTestArchive(t, "zip", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(result.Members, ",") != "a/b/c/ok.py" || len(result.Rejected) != 1 || !strings.Contains(result.Rejected[0].Reason, "вложенность каталогов больше 3") {
+		t.Fatalf("распакованы %v, отклонены %v", result.Members, result.Rejected)
+	}
+}
+
+func TestExtractArchiveDuplicateMembers(t *testing.T) {
+	setArchiveLimits(t, testArchiveLimits)
+	entries := []archiveEntry{
+		{name: "main.py", content: "print('первый')\n"},
+		{name: "lib/a.py", content: "x = 1\n"},
+		{name: "main.py", content: "print('второй')\n"},
+		{name: "./lib//a.py", content: "x = 2\n"},
+	}
+	for _, kind := range []string{"zip", "tar.gz"} {
+		data := buildZip(t, entries...)
+		if kind == "tar.gz" {
+			data = buildTarGz(t, entries...)
+		}
+		result, dir, err := extractTestArchive(t, kind, data)
+		if err != nil {
+			t.Fatalf("%s: %v", kind, err)
+		}
+		if strings.Join(result.Members, ",") != "main.py,lib/a.py" {
+			t.Errorf("%s: распакованы %v", kind, result.Members)
+		}
+		if got := rejectedPaths(result); strings.Join(got, ",") != "./lib//a.py,main.py" {
+			t.Errorf("%s: отклонены %v", kind, got)
+		}
+		// Остаётся первый файл, повтор его не перезаписывает
+		if data, _ := os.ReadFile(filepath.Join(dir, "main.py")); string(data) != "print('первый')\n" {
+			t.Errorf("%s: main.py = %q", kind, data)
+		}
+	}
+}
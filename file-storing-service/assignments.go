@@ -0,0 +1,321 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"shared/formats"
+	"shared/pagination"
+)
+
+// Задания, в которые студенты сдают работы:
+//
+//	GET    /assignments       — список заданий (фильтр course)
+//	POST   /assignments       — создать задание
+//	GET    /assignments/{id}  — задание
+//	PUT    /assignments/{id}  — изменить задание
+//	DELETE /assignments/{id}  — удалить задание, по которому ещё нет работ
+//
+// Работа принимается только в существующее задание и только после opens_at. После
+// closes_at она отклоняется или, если у задания allow_late, принимается с отметкой late.
+type Assignment struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	// Course — id курса из /courses; пусто — задание вне курса.
+	Course   string     `json:"course"`
+	Language string     `json:"language"`
+	OpensAt  *time.Time `json:"opens_at"`
+	ClosesAt *time.Time `json:"closes_at"`
+	// AllowLate — принимать работы после closes_at с отметкой late вместо отказа.
+	AllowLate bool `json:"allow_late"`
+	// AllowedFormats — имена форматов из реестра (python, zip, …); пустой список — все форматы.
+	AllowedFormats []string `json:"allowed_formats"`
+	// MaxSize — лимит размера работы в байтах; 0 — лимит из UPLOAD_MAX_SIZE_BY_ASSIGNMENT
+	// или UPLOAD_MAX_SIZE.
+	MaxSize   int64       `json:"max_size"`
+	CreatedAt interface{} `json:"created_at"`
+}
+
+// backfillAssignments заводит задания для работ, загруженных, когда assignment_id был
+// свободным полем формы: без сроков и ограничений, с id в качестве названия.
+func backfillAssignments() {
+	query := `
+	INSERT OR IGNORE INTO assignments (id, title)
+	SELECT DISTINCT assignment_id, assignment_id FROM files
+	UNION
+	SELECT DISTINCT assignment_id, assignment_id FROM tus_uploads WHERE file_id IS NULL
+	`
+	result, err := db.Exec(query)
+	if err != nil {
+		fmt.Println("Ошибка при создании заданий для старых работ:", err)
+		return
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		fmt.Printf("Созданы задания для старых работ: %d\n", n)
+	}
+}
+
+// assignmentColumns — колонки assignments в том порядке, в котором их читает scanAssignment.
+const assignmentColumns = `id, title, course, language, opens_at, closes_at, allow_late, allowed_formats, max_size, created_at`
+
+func scanAssignment(row rowScanner) (Assignment, error) {
+	var a Assignment
+	var opensAt, closesAt sql.NullString
+	var allowedFormats string
+	err := row.Scan(&a.ID, &a.Title, &a.Course, &a.Language, &opensAt, &closesAt, &a.AllowLate, &allowedFormats, &a.MaxSize, &a.CreatedAt)
+	if err != nil {
+		return a, err
+	}
+	a.OpensAt, a.ClosesAt = parseStoredTime(opensAt), parseStoredTime(closesAt)
+	a.AllowedFormats = []string{}
+	if allowedFormats != "" {
+		a.AllowedFormats = strings.Split(allowedFormats, ",")
+	}
+	return a, nil
+}
+
+// parseStoredTime читает время, записанное storedTime; пустое значение — nil.
+func parseStoredTime(v sql.NullString) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	t, _, err := pagination.ParseTime(v.String)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+// storedTime — время в формате CURRENT_TIMESTAMP, чтобы его можно было сравнивать с uploaded_at.
+func storedTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format(pagination.SQLiteTimeLayout)
+}
+
+func loadAssignment(id string) (Assignment, error) {
+	return scanAssignment(db.QueryRow(`SELECT `+assignmentColumns+` FROM assignments WHERE id = ?`, id))
+}
+
+// validate проверяет задание из тела POST и PUT.
+func (a Assignment) validate() error {
+	if a.ID == "" || strings.ContainsAny(a.ID, "/?#") {
+		return errors.New("id обязателен и не должен содержать / ? #")
+	}
+	if strings.TrimSpace(a.Title) == "" {
+		return errors.New("title обязателен")
+	}
+	if a.OpensAt != nil && a.ClosesAt != nil && !a.ClosesAt.After(*a.OpensAt) {
+		return errors.New("closes_at должен быть позже opens_at")
+	}
+	if a.MaxSize < 0 {
+		return errors.New("max_size не может быть отрицательным")
+	}
+	for _, name := range a.AllowedFormats {
+		if formats.ByName(name) == nil {
+			return fmt.Errorf("неизвестный формат %q", name)
+		}
+	}
+	if a.Course != "" {
+		if ok, err := courseExists(a.Course); err != nil || !ok {
+			return fmt.Errorf("курс %q не найден", a.Course)
+		}
+	}
+	return nil
+}
+
+// admit проверяет, принимает ли задание файл filename, поданный в момент at, и
+// возвращает, сдана ли работа после срока.
+func (a Assignment) admit(filename string, at time.Time) (bool, *uploadError) {
+	if a.OpensAt != nil && at.Before(*a.OpensAt) {
+		message := fmt.Sprintf(`Приём работ по заданию %s откроется %s`, a.ID, a.OpensAt.UTC().Format(time.RFC3339))
+		return false, &uploadError{code: http.StatusForbidden, message: message}
+	}
+	late := a.ClosesAt != nil && at.After(*a.ClosesAt)
+	if late && !a.AllowLate {
+		message := fmt.Sprintf(`Приём работ по заданию %s закрыт %s`, a.ID, a.ClosesAt.UTC().Format(time.RFC3339))
+		return false, &uploadError{code: http.StatusForbidden, message: message}
+	}
+	if format := formats.For(filename); format != nil && len(a.AllowedFormats) > 0 {
+		allowed := false
+		for _, name := range a.AllowedFormats {
+			allowed = allowed || name == format.Name
+		}
+		if !allowed {
+			message := fmt.Sprintf(`Задание %s не принимает формат %s. Разрешены: %s`,
+				a.ID, filepath.Ext(filename), strings.Join(a.AllowedFormats, ", "))
+			return false, &uploadError{code: http.StatusUnsupportedMediaType, message: message}
+		}
+	}
+	return late, nil
+}
+
+// checkAssignment проверяет работу студента по заданию assignmentID и возвращает, опоздала ли она.
+func checkAssignment(assignmentID string, studentID string, filename string, at time.Time) (bool, *uploadError) {
+	a, err := loadAssignment(assignmentID)
+	if err == sql.ErrNoRows {
+		return false, &uploadError{code: http.StatusBadRequest, message: fmt.Sprintf(`Задание %s не найдено`, assignmentID)}
+	}
+	if err != nil {
+		fmt.Println("Ошибка при загрузке задания:", err)
+		return false, &uploadError{code: http.StatusInternalServerError, message: `Ошибка при запросе к БД`}
+	}
+	if uerr := checkEnrolment(a.Course, studentID); uerr != nil {
+		return false, uerr
+	}
+	return a.admit(filename, at)
+}
+
+func assignmentsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		listAssignmentsHandler(w, r)
+	case http.MethodPost:
+		saveAssignmentHandler(w, r, "")
+	default:
+		http.Error(w, "Only GET and POST methods are allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func assignmentHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Path[len("/assignments/"):]
+	if id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		a, err := loadAssignment(id)
+		if err == sql.ErrNoRows {
+			http.Error(w, `Задание не найдено`, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(a)
+	case http.MethodPut:
+		saveAssignmentHandler(w, r, id)
+	case http.MethodDelete:
+		deleteAssignmentHandler(w, id)
+	default:
+		http.Error(w, "Only GET, PUT and DELETE methods are allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func listAssignmentsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	var filters pagination.Filters
+	if v := r.URL.Query().Get("course"); v != "" {
+		filters.Add("course = ?", v)
+	}
+	rows, err := db.Query(`SELECT `+assignmentColumns+` FROM assignments`+filters.Where()+` ORDER BY id ASC`, filters.Args...)
+	if err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	assignments := []Assignment{}
+	for rows.Next() {
+		a, err := scanAssignment(rows)
+		if err != nil {
+			continue
+		}
+		assignments = append(assignments, a)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"assignments": assignments,
+	})
+}
+
+// saveAssignmentHandler создаёт задание (id из тела) или заменяет поля задания id.
+func saveAssignmentHandler(w http.ResponseWriter, r *http.Request, id string) {
+	w.Header().Set("Content-Type", "application/json")
+	var a Assignment
+	if err := json.NewDecoder(r.Body).Decode(&a); err != nil {
+		http.Error(w, `Ошибка при парсинге JSON`, http.StatusBadRequest)
+		return
+	}
+	if id != "" {
+		a.ID = id
+	}
+	a.Title = strings.TrimSpace(a.Title)
+	if err := a.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	allowedFormats := strings.Join(a.AllowedFormats, ",")
+	code := http.StatusOK
+	if id == "" {
+		query := `
+		INSERT INTO assignments (id, title, course, language, opens_at, closes_at, allow_late, allowed_formats, max_size)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`
+		_, err := db.Exec(query, a.ID, a.Title, a.Course, a.Language, storedTime(a.OpensAt), storedTime(a.ClosesAt), a.AllowLate, allowedFormats, a.MaxSize)
+		if err != nil && strings.Contains(err.Error(), "UNIQUE") {
+			http.Error(w, `Задание с таким id уже есть`, http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, `Ошибка при сохранении данных в БД`, http.StatusInternalServerError)
+			return
+		}
+		code = http.StatusCreated
+		fmt.Printf("Создано задание %s: %s\n", a.ID, a.Title)
+	} else {
+		query := `
+		UPDATE assignments SET title = ?, course = ?, language = ?, opens_at = ?, closes_at = ?, allow_late = ?, allowed_formats = ?, max_size = ?
+		WHERE id = ?
+		`
+		result, err := db.Exec(query, a.Title, a.Course, a.Language, storedTime(a.OpensAt), storedTime(a.ClosesAt), a.AllowLate, allowedFormats, a.MaxSize, a.ID)
+		if err != nil {
+			http.Error(w, `Ошибка при сохранении данных в БД`, http.StatusInternalServerError)
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			http.Error(w, `Задание не найдено`, http.StatusNotFound)
+			return
+		}
+	}
+	saved, err := loadAssignment(a.ID)
+	if err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(saved)
+}
+
+// deleteAssignmentHandler удаляет задание. Задание с работами не удаляется: работы
+// остались бы без задания.
+func deleteAssignmentHandler(w http.ResponseWriter, id string) {
+	var submissions int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM files WHERE assignment_id = ?`, id).Scan(&submissions); err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
+	if submissions > 0 {
+		http.Error(w, fmt.Sprintf(`По заданию уже сдано работ: %d, его нельзя удалить`, submissions), http.StatusConflict)
+		return
+	}
+	result, err := db.Exec(`DELETE FROM assignments WHERE id = ?`, id)
+	if err != nil {
+		http.Error(w, `Ошибка при удалении задания`, http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, `Задание не найдено`, http.StatusNotFound)
+		return
+	}
+	fmt.Println("Удалено задание", id)
+	w.WriteHeader(http.StatusNoContent)
+}
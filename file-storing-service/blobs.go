@@ -0,0 +1,141 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Загрузки хранятся по содержимому: файл лежит в хранилище под ключом
+// blobs/<первые 2 символа хэша>/<sha256><расширение>, поэтому одинаковые работы хранятся
+// один раз, а имена не пересекаются. Расширение остаётся в ключе, чтобы было видно формат.
+// Проект из архива лежит под префиксом blobs/<xx>/<sha256 архива>/.
+//
+// Работа разбирается в рабочем каталоге, который повторяет раскладку ключей (рядом с блобом
+// появляются UTF-8 копия, текст документа, файлы проекта), а в хранилище всё публикуется
+// одним шагом в конце загрузки.
+
+// hashUpload считает SHA-256 и размер загруженного файла и возвращает чтение в начало.
+func hashUpload(file io.ReadSeeker) (string, int64, error) {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", 0, err
+	}
+	h := sha256.New()
+	size, err := io.Copy(h, file)
+	if err != nil {
+		return "", 0, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+func blobKey(hash string, ext string) string {
+	return "blobs/" + hash[:2] + "/" + hash + strings.ToLower(ext)
+}
+
+// saveWorkFile сохраняет загруженный файл в рабочий каталог.
+func saveWorkFile(file io.ReadSeeker, path string) error {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	dst, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, file)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// workKey переводит путь в рабочем каталоге в ключ хранилища.
+func workKey(work string, path string) (string, error) {
+	rel, err := filepath.Rel(work, path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// publishWork кладёт в хранилище все файлы рабочего каталога. Объект primary пишется
+// последним: по его наличию следующая загрузка того же содержимого понимает, что всё
+// остальное уже в хранилище.
+func publishWork(work string, primary string) error {
+	var keys []string
+	err := filepath.WalkDir(work, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		key, err := workKey(work, path)
+		if err == nil && key != primary {
+			keys = append(keys, key)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range append(keys, primary) {
+		f, err := os.Open(filepath.Join(work, filepath.FromSlash(key)))
+		if err != nil {
+			return err
+		}
+		err = storage.Put(key, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillHashes считает хэш и размер работ, загруженных до появления хранилища блобов.
+// Сами файлы остаются на старых местах.
+func backfillHashes() {
+	rows, err := db.Query(`SELECT id, file_path FROM files WHERE sha256 IS NULL`)
+	if err != nil {
+		fmt.Println("Ошибка при запросе к БД", err)
+		return
+	}
+	type unhashed struct {
+		id   int
+		path string
+	}
+	var files []unhashed
+	for rows.Next() {
+		var f unhashed
+		if err := rows.Scan(&f.id, &f.path); err == nil {
+			files = append(files, f)
+		}
+	}
+	rows.Close()
+
+	for _, f := range files {
+		info, err := os.Stat(f.path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		file, err := os.Open(f.path)
+		if err != nil {
+			continue
+		}
+		hash, size, err := hashUpload(file)
+		file.Close()
+		if err != nil {
+			fmt.Printf("Не удалось посчитать хэш файла %d: %v\n", f.id, err)
+			continue
+		}
+		db.Exec(`UPDATE files SET sha256 = ?, size = ? WHERE id = ?`, hash, size, f.id)
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"archive/zip"
+	"database/sql"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"shared/formats"
+)
+
+// storedFile — то, что нужно для выдачи содержимого работы.
+type storedFile struct {
+	ID           string
+	Path         string
+	TextPath     string
+	Filename     string
+	ContentCheck string
+}
+
+func loadStoredFile(id string) (storedFile, error) {
+	query := `
+	SELECT id, file_path, COALESCE(text_path, file_path), COALESCE(original_filename, ''), COALESCE(content_check, 'text')
+	FROM files WHERE id = ?
+	`
+	var f storedFile
+	err := db.QueryRow(query, id).Scan(&f.ID, &f.Path, &f.TextPath, &f.Filename, &f.ContentCheck)
+	if err != nil {
+		return f, err
+	}
+	// Работы, загруженные до сохранения имени файла
+	if f.Filename == "" {
+		f.Filename = filepath.Base(f.Path)
+	}
+	return f, nil
+}
+
+func loadStoredFileOrError(w http.ResponseWriter, id string) (storedFile, bool) {
+	f, err := loadStoredFile(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, `Файл не найден`, http.StatusNotFound)
+		return f, false
+	}
+	if err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return f, false
+	}
+	return f, true
+}
+
+// fileContentHandler отдаёт работу как её загрузили, с исходным именем в Content-Disposition.
+// Архив хранится распакованным, поэтому проект отдаётся собранным заново zip-архивом.
+func fileContentHandler(w http.ResponseWriter, r *http.Request, id string) {
+	f, ok := loadStoredFileOrError(w, id)
+	if !ok {
+		return
+	}
+	members, err := loadMembers(f.ID)
+	if err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
+	if len(members) > 0 {
+		name := f.Filename
+		if format := formats.For(name); format == nil || format.Extractor != "zip" {
+			if format != nil {
+				name = strings.TrimSuffix(name, matchedExtension(name, format))
+			}
+			name += ".zip"
+		}
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+		if err := writeProjectZip(w, f.Path, members); err != nil {
+			fmt.Println("Ошибка при упаковке проекта:", err)
+		}
+		return
+	}
+	file, err := storage.Open(f.Path)
+	if err == errObjectNotFound {
+		http.Error(w, `Содержимое файла не найдено`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		fmt.Println("Ошибка при чтении из хранилища:", err)
+		http.Error(w, `Ошибка при чтении файла`, http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+	contentType := "application/octet-stream"
+	if format := formats.For(f.Filename); format != nil && format.MIME != "" {
+		contentType = format.MIME
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": f.Filename}))
+	// Локальный файл отдаётся с поддержкой Range, объект из S3 — потоком
+	if seeker, ok := file.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, f.Filename, time.Time{}, seeker)
+		return
+	}
+	io.Copy(w, file)
+}
+
+func matchedExtension(name string, format *formats.Format) string {
+	lower := strings.ToLower(name)
+	for _, ext := range format.Extensions {
+		if strings.HasSuffix(lower, ext) {
+			return name[len(name)-len(ext):]
+		}
+	}
+	return ""
+}
+
+func writeProjectZip(w io.Writer, projectKey string, members []string) error {
+	zw := zip.NewWriter(w)
+	for _, member := range members {
+		src, err := storage.Open(projectKey + "/" + member)
+		if err != nil {
+			return err
+		}
+		dst, err := zw.Create(member)
+		if err == nil {
+			_, err = io.Copy(dst, src)
+		}
+		src.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+type previewFile struct {
+	Name  string
+	Note  string
+	Lines []template.HTML
+}
+
+var previewTemplate = template.Must(template.New("preview").Funcs(template.FuncMap{
+	"inc": func(i int) int { return i + 1 },
+}).Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 16px; background: #fafafa; }
+h2 { font-size: 15px; margin: 24px 0 8px; }
+.note { color: #666; }
+table.code { border-collapse: collapse; background: #fff; border: 1px solid #ddd; font: 13px/1.45 monospace; width: 100%; }
+td.ln { color: #999; text-align: right; padding: 0 8px; user-select: none; border-right: 1px solid #eee; width: 1%; }
+td.ln a { color: inherit; text-decoration: none; }
+td.src { white-space: pre; padding: 0 12px; }
+tr:target { background: #fff8c5; }
+.kw { color: #0033b3; font-weight: bold; }
+.str { color: #067d17; }
+.num { color: #1750eb; }
+.com { color: #8c8c8c; font-style: italic; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{range $f, $file := .Files}}<h2>{{$file.Name}}</h2>
+{{if $file.Note}}<p class="note">{{$file.Note}}</p>
+{{else}}<table class="code">
+{{range $i, $line := $file.Lines}}<tr id="f{{$f}}-L{{inc $i}}"><td class="ln"><a href="#f{{$f}}-L{{inc $i}}">{{inc $i}}</a></td><td class="src">{{$line}}</td></tr>
+{{end}}</table>
+{{end}}{{end}}</body>
+</html>
+`))
+
+// previewMaxSize — сколько байт текста показывается на одной странице предпросмотра: у
+// проекта файлов может быть много, а страница растёт вместе с текстом.
+var previewMaxSize = envInt64("PREVIEW_MAX_SIZE", 1<<20)
+
+// filePreviewHandler показывает работу HTML-страницей с подсветкой синтаксиса и номерами строк.
+// Показывается UTF-8 текст, по которому идёт анализ; у проекта — все файлы или один (?member=),
+// у блокнота Jupyter — исходный текст ячеек, а не JSON.
+func filePreviewHandler(w http.ResponseWriter, r *http.Request, id string) {
+	f, ok := loadStoredFileOrError(w, id)
+	if !ok {
+		return
+	}
+	members, err := loadMembers(f.ID)
+	if err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
+	var files []previewFile
+	budget := previewMaxSize
+	addPreview := func(name string, content []byte) {
+		if int64(len(content)) > budget {
+			note := fmt.Sprintf("Файл не помещается в предпросмотр (до %d байт на страницу), его можно скачать целиком", previewMaxSize)
+			files = append(files, previewFile{Name: name, Note: note})
+			return
+		}
+		budget -= int64(len(content))
+		files = append(files, previewFor(name, content)...)
+	}
+	switch {
+	case f.ContentCheck == contentBinary:
+		files = append(files, previewFile{Name: f.Filename, Note: "Бинарный файл, предпросмотр недоступен"})
+	case f.ContentCheck == contentNoText:
+		files = append(files, previewFile{Name: f.Filename, Note: "В документе нет извлекаемого текста"})
+	case len(members) > 0:
+		only := r.URL.Query().Get("member")
+		for _, member := range members {
+			if only != "" && member != only {
+				continue
+			}
+			content, err := readObject(f.TextPath + "/" + member)
+			if err != nil {
+				previewReadError(w, err)
+				return
+			}
+			addPreview(member, content)
+		}
+		if len(files) == 0 {
+			http.Error(w, `Файл проекта не найден`, http.StatusNotFound)
+			return
+		}
+	default:
+		content, err := readObject(f.TextPath)
+		if err != nil {
+			previewReadError(w, err)
+			return
+		}
+		addPreview(f.Filename, content)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = previewTemplate.Execute(w, map[string]interface{}{
+		"Title": fmt.Sprintf("Работа %s: %s", f.ID, f.Filename),
+		"Files": files,
+	})
+	if err != nil {
+		fmt.Println("Ошибка при отрисовке предпросмотра:", err)
+	}
+}
+
+func previewReadError(w http.ResponseWriter, err error) {
+	if err == errObjectNotFound {
+		http.Error(w, `Содержимое файла не найдено`, http.StatusNotFound)
+		return
+	}
+	fmt.Println("Ошибка при чтении из хранилища:", err)
+	http.Error(w, `Ошибка при чтении файла`, http.StatusInternalServerError)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// documentExtractors извлекают текст из документов: docx и odt хранятся как zip-архивы XML,
+// PDF разбирается в pdf.go. Ключ — способ извлечения из реестра форматов. Оригинал
+// сохраняется как есть, а текст для анализа — рядом в .txt.
+var documentExtractors = map[string]func(r io.ReaderAt, size int64) (string, error){
+	"docx": extractDocx,
+	"odt":  extractODT,
+	"pdf":  extractPDF,
+}
+
+// xmlTextRules описывают, где в XML документа лежит текст.
+type xmlTextRules struct {
+	text       map[string]bool   // элементы с текстом; nil — любой текст внутри scope
+	scope      string            // элемент, внутри которого лежит содержимое документа
+	inline     map[string]string // пустые элементы, заменяемые символом (табуляция, перенос)
+	paragraphs map[string]bool   // элементы, после которых ставится перенос строки
+}
+
+var docxRules = xmlTextRules{
+	text:       map[string]bool{"t": true},
+	inline:     map[string]string{"tab": "\t", "br": "\n", "cr": "\n"},
+	paragraphs: map[string]bool{"p": true},
+}
+
+var odtRules = xmlTextRules{
+	scope:      "body",
+	inline:     map[string]string{"tab": "\t", "line-break": "\n"},
+	paragraphs: map[string]bool{"p": true, "h": true},
+}
+
+// extractDocx извлекает текст из word/document.xml документа Office Open XML.
+func extractDocx(r io.ReaderAt, size int64) (string, error) {
+	data, err := readZipMember(r, size, "word/document.xml")
+	if err != nil {
+		return "", err
+	}
+	return extractXMLText(data, docxRules)
+}
+
+// extractODT извлекает текст из content.xml документа OpenDocument.
+func extractODT(r io.ReaderAt, size int64) (string, error) {
+	data, err := readZipMember(r, size, "content.xml")
+	if err != nil {
+		return "", err
+	}
+	return extractXMLText(data, odtRules)
+}
+
+func readZipMember(r io.ReaderAt, size int64, name string) ([]byte, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("файл не является документом: %w", err)
+	}
+	for _, f := range zr.File {
+		if f.Name != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(io.LimitReader(rc, limits.MaxTotalSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(data)) > limits.MaxTotalSize {
+			return nil, fmt.Errorf("%w: %s больше %d байт", errArchiveLimit, name, limits.MaxTotalSize)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("в документе нет %s", name)
+}
+
+func extractXMLText(data []byte, rules xmlTextRules) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var sb strings.Builder
+	depth, scopeDepth := 0, 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("повреждённый XML документа: %w", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if rules.text[t.Name.Local] {
+				depth++
+			}
+			if t.Name.Local == rules.scope {
+				scopeDepth++
+			}
+			if s, ok := rules.inline[t.Name.Local]; ok {
+				sb.WriteString(s)
+			}
+			// В ODT несколько пробелов подряд записываются как <text:s text:c="3"/>
+			if rules.text == nil && t.Name.Local == "s" {
+				sb.WriteString(strings.Repeat(" ", spaceCount(t)))
+			}
+		case xml.EndElement:
+			if rules.text[t.Name.Local] {
+				depth--
+			}
+			if t.Name.Local == rules.scope {
+				scopeDepth--
+			}
+			if rules.paragraphs[t.Name.Local] {
+				sb.WriteString("\n")
+			}
+		case xml.CharData:
+			if depth > 0 || (rules.text == nil && scopeDepth > 0) {
+				sb.Write(t)
+			}
+		}
+	}
+	return sb.String(), nil
+}
+
+func spaceCount(t xml.StartElement) int {
+	for _, attr := range t.Attr {
+		if attr.Name.Local == "c" {
+			if n, err := strconv.Atoi(attr.Value); err == nil && n > 0 && n < 1000 {
+				return n
+			}
+		}
+	}
+	return 1
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Duplicates — работы других студентов по тому же заданию, совпадающие с загруженной
+// байт в байт (Exact) или после нормализации пробелов (Normalized). Точные копии
+// попадают только в Exact.
+type Duplicates struct {
+	Exact      []int64 `json:"exact"`
+	Normalized []int64 `json:"normalized"`
+}
+
+// normalizedHash считает SHA-256 текста работы без учёта пробельных символов: все пробелы,
+// табуляции и переносы строк схлопываются в один пробел. Считается по UTF-8 копии, поэтому
+// одна и та же работа в CP1251 и UTF-8 тоже совпадёт. Для проекта текст файлов склеивается
+// в порядке путей. Пустой текст (например, скан PDF) не хэшируется.
+func normalizedHash(textPath string, members []string) (string, error) {
+	paths := []string{textPath}
+	if members != nil {
+		sorted := append([]string(nil), members...)
+		sort.Strings(sorted)
+		paths = paths[:0]
+		for _, m := range sorted {
+			paths = append(paths, filepath.Join(textPath, filepath.FromSlash(m)))
+		}
+	}
+	h := sha256.New()
+	empty := true
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", err
+		}
+		fields := strings.Fields(string(data))
+		if len(fields) == 0 {
+			continue
+		}
+		if !empty {
+			h.Write([]byte(" "))
+		}
+		h.Write([]byte(strings.Join(fields, " ")))
+		empty = false
+	}
+	if empty {
+		return "", nil
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// findDuplicates ищет копии среди работ других студентов по тому же заданию.
+func findDuplicates(studentID string, assignmentID string, hash string, normalized string) (Duplicates, error) {
+	dups := Duplicates{Exact: []int64{}, Normalized: []int64{}}
+	query := `
+	SELECT id, sha256 = ?
+	FROM files
+	WHERE assignment_id = ? AND student_id != ? AND (sha256 = ? OR (? != '' AND normalized_sha256 = ?))
+	ORDER BY id ASC
+	`
+	rows, err := db.Query(query, hash, assignmentID, studentID, hash, normalized, normalized)
+	if err != nil {
+		return dups, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var exact bool
+		if err := rows.Scan(&id, &exact); err != nil {
+			return dups, err
+		}
+		if exact {
+			dups.Exact = append(dups.Exact, id)
+		} else {
+			dups.Normalized = append(dups.Normalized, id)
+		}
+	}
+	return dups, rows.Err()
+}
+
+// backfillNormalizedHashes считает нормализованный хэш работ, загруженных до появления
+// поиска копий. Пустая строка означает, что текста для хэша нет.
+func backfillNormalizedHashes() {
+	rows, err := db.Query(`SELECT id, COALESCE(text_path, file_path) FROM files WHERE normalized_sha256 IS NULL`)
+	if err != nil {
+		fmt.Println("Ошибка при запросе к БД", err)
+		return
+	}
+	type unhashed struct {
+		id   string
+		path string
+	}
+	var files []unhashed
+	for rows.Next() {
+		var f unhashed
+		if err := rows.Scan(&f.id, &f.path); err == nil {
+			files = append(files, f)
+		}
+	}
+	rows.Close()
+
+	for _, f := range files {
+		info, err := os.Stat(f.path)
+		if err != nil {
+			continue
+		}
+		var members []string
+		if info.IsDir() {
+			if members, err = loadMembers(f.id); err != nil {
+				continue
+			}
+		}
+		normalized, err := normalizedHash(f.path, members)
+		if err != nil {
+			fmt.Printf("Не удалось посчитать нормализованный хэш файла %s: %v\n", f.id, err)
+			continue
+		}
+		db.Exec(`UPDATE files SET normalized_sha256 = ? WHERE id = ?`, normalized, f.id)
+	}
+}
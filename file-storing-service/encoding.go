@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Кодировки, которые определяются при загрузке.
+const (
+	encodingUTF8    = "utf-8"
+	encodingUTF8BOM = "utf-8-bom"
+	encodingUTF16LE = "utf-16le"
+	encodingUTF16BE = "utf-16be"
+	encodingCP1251  = "windows-1251"
+	encodingKOI8R   = "koi8-r"
+)
+
+// Символы Unicode для байтов 0x80–0xFF однобайтовых кириллических кодировок.
+var cp1251Table = [128]rune{
+	0x0402, 0x0403, 0x201A, 0x0453, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x20AC, 0x2030, 0x0409, 0x2039, 0x040A, 0x040C, 0x040B, 0x040F,
+	0x0452, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0xFFFD, 0x2122, 0x0459, 0x203A, 0x045A, 0x045C, 0x045B, 0x045F,
+	0x00A0, 0x040E, 0x045E, 0x0408, 0x00A4, 0x0490, 0x00A6, 0x00A7,
+	0x0401, 0x00A9, 0x0404, 0x00AB, 0x00AC, 0x00AD, 0x00AE, 0x0407,
+	0x00B0, 0x00B1, 0x0406, 0x0456, 0x0491, 0x00B5, 0x00B6, 0x00B7,
+	0x0451, 0x2116, 0x0454, 0x00BB, 0x0458, 0x0405, 0x0455, 0x0457,
+	0x0410, 0x0411, 0x0412, 0x0413, 0x0414, 0x0415, 0x0416, 0x0417,
+	0x0418, 0x0419, 0x041A, 0x041B, 0x041C, 0x041D, 0x041E, 0x041F,
+	0x0420, 0x0421, 0x0422, 0x0423, 0x0424, 0x0425, 0x0426, 0x0427,
+	0x0428, 0x0429, 0x042A, 0x042B, 0x042C, 0x042D, 0x042E, 0x042F,
+	0x0430, 0x0431, 0x0432, 0x0433, 0x0434, 0x0435, 0x0436, 0x0437,
+	0x0438, 0x0439, 0x043A, 0x043B, 0x043C, 0x043D, 0x043E, 0x043F,
+	0x0440, 0x0441, 0x0442, 0x0443, 0x0444, 0x0445, 0x0446, 0x0447,
+	0x0448, 0x0449, 0x044A, 0x044B, 0x044C, 0x044D, 0x044E, 0x044F,
+}
+
+var koi8rTable = [128]rune{
+	0x2500, 0x2502, 0x250C, 0x2510, 0x2514, 0x2518, 0x251C, 0x2524,
+	0x252C, 0x2534, 0x253C, 0x2580, 0x2584, 0x2588, 0x258C, 0x2590,
+	0x2591, 0x2592, 0x2593, 0x2320, 0x25A0, 0x2219, 0x221A, 0x2248,
+	0x2264, 0x2265, 0x00A0, 0x2321, 0x00B0, 0x00B2, 0x00B7, 0x00F7,
+	0x2550, 0x2551, 0x2552, 0x0451, 0x2553, 0x2554, 0x2555, 0x2556,
+	0x2557, 0x2558, 0x2559, 0x255A, 0x255B, 0x255C, 0x255D, 0x255E,
+	0x255F, 0x2560, 0x2561, 0x0401, 0x2562, 0x2563, 0x2564, 0x2565,
+	0x2566, 0x2567, 0x2568, 0x2569, 0x256A, 0x256B, 0x256C, 0x00A9,
+	0x044E, 0x0430, 0x0431, 0x0446, 0x0434, 0x0435, 0x0444, 0x0433,
+	0x0445, 0x0438, 0x0439, 0x043A, 0x043B, 0x043C, 0x043D, 0x043E,
+	0x043F, 0x044F, 0x0440, 0x0441, 0x0442, 0x0443, 0x0436, 0x0432,
+	0x044C, 0x044B, 0x0437, 0x0448, 0x044D, 0x0449, 0x0447, 0x044A,
+	0x042E, 0x0410, 0x0411, 0x0426, 0x0414, 0x0415, 0x0424, 0x0413,
+	0x0425, 0x0418, 0x0419, 0x041A, 0x041B, 0x041C, 0x041D, 0x041E,
+	0x041F, 0x042F, 0x0420, 0x0421, 0x0422, 0x0423, 0x0416, 0x0412,
+	0x042C, 0x042B, 0x0417, 0x0428, 0x042D, 0x0429, 0x0427, 0x042A,
+}
+
+// frequentRussian — самые частые буквы русского языка, по ним выбирается между CP1251 и KOI8-R.
+const frequentRussian = "оеаинтсрвлкмдпу"
+
+// detectEncoding определяет кодировку текста: по BOM, по расположению нулевых байтов для UTF-16
+// без BOM, по корректности UTF-8 и, наконец, по частоте русских букв для CP1251 и KOI8-R.
+func detectEncoding(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return encodingUTF8BOM
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return encodingUTF16LE
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return encodingUTF16BE
+	}
+	if enc := detectUTF16(data); enc != "" {
+		return enc
+	}
+	if utf8.Valid(data) {
+		return encodingUTF8
+	}
+	cp1251 := strings.ToLower(decodeSingleByte(data, &cp1251Table))
+	koi8r := strings.ToLower(decodeSingleByte(data, &koi8rTable))
+	if russianScore(koi8r) > russianScore(cp1251) {
+		return encodingKOI8R
+	}
+	return encodingCP1251
+}
+
+// detectUTF16 распознаёт UTF-16 без BOM: у латиницы старший байт равен 0x00, у кириллицы — 0x04,
+// поэтому в одной из позиций (чётной или нечётной) такие байты встречаются почти всегда.
+func detectUTF16(data []byte) string {
+	if len(data) < 4 || len(data)%2 != 0 || bytes.IndexByte(data, 0) == -1 {
+		return ""
+	}
+	even, odd := 0, 0
+	for i := 0; i+1 < len(data); i += 2 {
+		if data[i] == 0x00 || data[i] == 0x04 {
+			even++
+		}
+		if data[i+1] == 0x00 || data[i+1] == 0x04 {
+			odd++
+		}
+	}
+	pairs := len(data) / 2
+	switch {
+	case odd*10 >= pairs*9 && even*10 < pairs*5:
+		return encodingUTF16LE
+	case even*10 >= pairs*9 && odd*10 < pairs*5:
+		return encodingUTF16BE
+	}
+	return ""
+}
+
+// looksLikeUTF16 отличает текст в UTF-16 от бинарного файла, в котором тоже есть нулевые байты.
+func looksLikeUTF16(head []byte) bool {
+	if bytes.HasPrefix(head, []byte{0xFF, 0xFE}) || bytes.HasPrefix(head, []byte{0xFE, 0xFF}) {
+		return true
+	}
+	return detectUTF16(head[:len(head)&^1]) != ""
+}
+
+func decodeSingleByte(data []byte, table *[128]rune) string {
+	var sb strings.Builder
+	sb.Grow(len(data) * 2)
+	for _, b := range data {
+		if b < 0x80 {
+			sb.WriteByte(b)
+		} else {
+			sb.WriteRune(table[b-0x80])
+		}
+	}
+	return sb.String()
+}
+
+func decodeUTF16(data []byte, bigEndian bool) string {
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		if bigEndian {
+			units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+		} else {
+			units = append(units, uint16(data[i+1])<<8|uint16(data[i]))
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+func russianScore(text string) int {
+	score := 0
+	for _, r := range text {
+		if strings.ContainsRune(frequentRussian, r) {
+			score++
+		}
+	}
+	return score
+}
+
+// toUTF8 перекодирует текст из обнаруженной кодировки в UTF-8 без BOM.
+func toUTF8(data []byte, encoding string) []byte {
+	switch encoding {
+	case encodingUTF8BOM:
+		return data[3:]
+	case encodingUTF16LE:
+		return []byte(decodeUTF16(bytes.TrimPrefix(data, []byte{0xFF, 0xFE}), false))
+	case encodingUTF16BE:
+		return []byte(decodeUTF16(bytes.TrimPrefix(data, []byte{0xFE, 0xFF}), true))
+	case encodingCP1251:
+		return []byte(decodeSingleByte(data, &cp1251Table))
+	case encodingKOI8R:
+		return []byte(decodeSingleByte(data, &koi8rTable))
+	}
+	return data
+}
+
+// normalizeFile определяет кодировку файла и, если это не UTF-8, сохраняет UTF-8 копию
+// в textPath, оставляя оригинал нетронутым. Возвращает кодировку и путь к тексту для анализа.
+func normalizeFile(path string, textPath string) (string, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", err
+	}
+	encoding := detectEncoding(data)
+	if encoding == encodingUTF8 {
+		return encoding, path, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(textPath), 0755); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(textPath, toUTF8(data, encoding), 0644); err != nil {
+		return "", "", err
+	}
+	return encoding, textPath, nil
+}
+
+// textFilePath возвращает путь для UTF-8 копии файла: work_1.txt → work_1.utf8.txt.
+func textFilePath(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + ".utf8" + ext
+}
+
+// normalizeProject перекодирует файлы проекта. Если хотя бы один файл не в UTF-8, рядом
+// с проектом создаётся каталог <проект>_utf8 со всеми файлами в UTF-8, и анализ идёт по нему.
+// Возвращает кодировки файлов, общую кодировку проекта и путь к тексту для анализа.
+func normalizeProject(dir string, members []string) (map[string]string, string, string, error) {
+	encodings := map[string]string{}
+	contents := map[string][]byte{}
+	converted := false
+	for _, member := range members {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(member)))
+		if err != nil {
+			return nil, "", "", err
+		}
+		encodings[member] = detectEncoding(data)
+		contents[member] = data
+		if encodings[member] != encodingUTF8 {
+			converted = true
+		}
+	}
+	projectEncoding := ""
+	for _, member := range members {
+		if projectEncoding == "" {
+			projectEncoding = encodings[member]
+		} else if projectEncoding != encodings[member] {
+			projectEncoding = "mixed"
+		}
+	}
+	if !converted {
+		return encodings, projectEncoding, dir, nil
+	}
+	textDir := dir + "_utf8"
+	for _, member := range members {
+		target := filepath.Join(textDir, filepath.FromSlash(member))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, "", "", err
+		}
+		if err := os.WriteFile(target, toUTF8(contents[member], encodings[member]), 0644); err != nil {
+			return nil, "", "", err
+		}
+	}
+	return encodings, projectEncoding, textDir, nil
+}
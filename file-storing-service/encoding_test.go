@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+	"unicode/utf16"
+)
+
+// encodeSingleByte кодирует текст однобайтовой кодировкой по таблице декодирования.
+func encodeSingleByte(t *testing.T, text string, table *[128]rune) []byte {
+	t.Helper()
+	var out []byte
+	for _, r := range text {
+		if r < 0x80 {
+			out = append(out, byte(r))
+			continue
+		}
+		found := false
+		for i, tr := range table {
+			if tr == r {
+				out, found = append(out, byte(0x80+i)), true
+				break
+			}
+		}
+		if !found {
+			t.Fatalf("символ %q не кодируется", r)
+		}
+	}
+	return out
+}
+
+func encodeUTF16(text string, bigEndian bool, bom bool) []byte {
+	var out []byte
+	if bom {
+		text = "\ufeff" + text
+	}
+	for _, u := range utf16.Encode([]rune(text)) {
+		if bigEndian {
+			out = binary.BigEndian.AppendUint16(out, u)
+		} else {
+			out = binary.LittleEndian.AppendUint16(out, u)
+		}
+	}
+	return out
+}
+
+func TestDetectEncoding(t *testing.T) {
+	const sample = "Привет, мир! Программа считает сумму."
+	const code = "# Вычисляем сумму\nprint(sum([1, 2, 3]))\n"
+	cases := []struct {
+		name string
+		data []byte
+		want string
+		// text — ожидаемый текст после перекодирования в UTF-8
+		text string
+	}{
+		{"ASCII", []byte("print('hello')\n"), encodingUTF8, "print('hello')\n"},
+		{"UTF-8", []byte(sample), encodingUTF8, sample},
+		{"UTF-8 с BOM", append([]byte{0xEF, 0xBB, 0xBF}, sample...), encodingUTF8BOM, sample},
+		{"CP1251", encodeSingleByte(t, sample, &cp1251Table), encodingCP1251, sample},
+		{"CP1251, код с комментарием", encodeSingleByte(t, code, &cp1251Table), encodingCP1251, code},
+		{"KOI8-R", encodeSingleByte(t, sample, &koi8rTable), encodingKOI8R, sample},
+		{"KOI8-R, код с комментарием", encodeSingleByte(t, code, &koi8rTable), encodingKOI8R, code},
+		{"UTF-16LE с BOM", encodeUTF16(sample, false, true), encodingUTF16LE, sample},
+		{"UTF-16BE с BOM", encodeUTF16(sample, true, true), encodingUTF16BE, sample},
+		{"UTF-16LE без BOM", encodeUTF16(sample, false, false), encodingUTF16LE, sample},
+		{"UTF-16BE без BOM", encodeUTF16(sample, true, false), encodingUTF16BE, sample},
+		{"UTF-16LE без BOM, латиница", encodeUTF16("print(x)\n", false, false), encodingUTF16LE, "print(x)\n"},
+		// Одно короткое слово: байты «мир» в CP1251 в KOI8-R дают «ЛХП», частых букв
+		// там две против трёх, и побеждает CP1251
+		{"коротко, CP1251", encodeSingleByte(t, "мир", &cp1251Table), encodingCP1251, "мир"},
+		// Байты «до» в KOI8-R читаются в CP1251 как «ДП»: частых букв в обоих вариантах
+		// поровну, и при равенстве выбирается CP1251 — слишком короткий текст угадывается неверно
+		{"неоднозначно, выбирается CP1251", encodeSingleByte(t, "до", &koi8rTable), encodingCP1251, "ДП"},
+	}
+	for _, c := range cases {
+		got := detectEncoding(c.data)
+		if got != c.want {
+			t.Errorf("%s: кодировка %s, ожидалась %s", c.name, got, c.want)
+			continue
+		}
+		if text := string(toUTF8(c.data, got)); text != c.text {
+			t.Errorf("%s: текст %q, ожидался %q", c.name, text, c.text)
+		}
+	}
+}
+
+func TestLooksLikeUTF16(t *testing.T) {
+	cases := []struct {
+		name string
+		head []byte
+		want bool
+	}{
+		{"UTF-16LE без BOM", encodeUTF16("Привет, мир", false, false), true},
+		{"UTF-16BE с BOM", encodeUTF16("x", true, true), true},
+		{"нечётная длина отрезанного начала", encodeUTF16("print(x)", false, false)[:15], true},
+		{"бинарный файл", []byte{0x7F, 'E', 'L', 'F', 0x02, 0x01, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00}, false},
+		{"ASCII", []byte("print(x)"), false},
+	}
+	for _, c := range cases {
+		if got := looksLikeUTF16(c.head); got != c.want {
+			t.Errorf("%s: получено %v, ожидалось %v", c.name, got, c.want)
+		}
+	}
+}
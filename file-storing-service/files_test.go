@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// listFilePages проходит список работ по страницам и возвращает ID в порядке выдачи.
+// Перед запросом каждой следующей страницы вызывается between.
+func listFilePages(t *testing.T, query string, between func()) []string {
+	t.Helper()
+	var ids []string
+	cursor := ""
+	for page := 0; page < 10; page++ {
+		w := httptest.NewRecorder()
+		listFilesHandler(w, httptest.NewRequest(http.MethodGet, "/files?"+query+"&cursor="+url.QueryEscape(cursor), nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: код %d: %s", query, w.Code, w.Body)
+		}
+		var resp struct {
+			Files      []FileInfo `json:"files"`
+			NextCursor string     `json:"next_cursor"`
+		}
+		json.NewDecoder(w.Body).Decode(&resp)
+		for _, f := range resp.Files {
+			ids = append(ids, f.ID)
+		}
+		if resp.NextCursor == "" {
+			return ids
+		}
+		cursor = resp.NextCursor
+		if between != nil {
+			between()
+		}
+	}
+	t.Fatalf("%s: список не закончился", query)
+	return nil
+}
+
+func TestListFilesPagination(t *testing.T) {
+	openTestDB(t)
+	_, err := db.Exec(`INSERT INTO files (id, student_id, assignment_id, file_path, status, size, uploaded_at) VALUES
+		(1, 'std_3', 'hw1', 'a', 'uploaded', 30, '2024-12-10 10:00:00'),
+		(2, 'std_1', 'hw1', 'b', 'uploaded', 20, '2024-12-10 09:00:00'),
+		(3, 'std_2', 'hw1', 'c', 'uploaded', 20, '2024-12-10 11:00:00'),
+		(4, 'std_1', 'hw1', 'd', 'uploaded', NULL, '2024-12-10 08:00:00'),
+		(5, 'std_2', 'hw1', 'e', 'uploaded', 10, '2024-12-10 12:00:00')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{"limit=2", []string{"1", "2", "3", "4", "5"}},
+		{"sort=-id&limit=2", []string{"5", "4", "3", "2", "1"}},
+		// Одинаковый размер упорядочивается по ID, отсутствующий размер считается нулём
+		{"sort=-size&limit=2", []string{"1", "3", "2", "5", "4"}},
+		{"sort=size&limit=1", []string{"4", "5", "2", "3", "1"}},
+		{"sort=uploaded_at&limit=3", []string{"4", "2", "1", "3", "5"}},
+		{"sort=student_id&limit=2", []string{"2", "4", "3", "5", "1"}},
+	}
+	for _, c := range cases {
+		if got := listFilePages(t, c.query, nil); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: получено %v, ожидалось %v", c.query, got, c.want)
+		}
+	}
+
+	// Последняя работа страницы удалена до запроса следующей: список продолжается с того же места
+	deleted := false
+	got := listFilePages(t, "sort=-size&limit=2", func() {
+		if !deleted {
+			db.Exec(`DELETE FROM files WHERE id = 3`)
+			deleted = true
+		}
+	})
+	if want := []string{"1", "3", "2", "5", "4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("удалённая запись курсора: получено %v, ожидалось %v", got, want)
+	}
+}
+
+func TestListFilesCursorErrors(t *testing.T) {
+	openTestDB(t)
+	for i := 0; i < 3; i++ {
+		insertTestFile(t)
+	}
+	w := httptest.NewRecorder()
+	listFilesHandler(w, httptest.NewRequest(http.MethodGet, "/files?sort=-size&limit=1", nil))
+	var resp struct {
+		NextCursor string `json:"next_cursor"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.NextCursor == "" {
+		t.Fatal("next_cursor пустой")
+	}
+
+	cases := []struct {
+		name  string
+		query string
+	}{
+		{"курсор другой сортировки", "sort=size&cursor=" + url.QueryEscape(resp.NextCursor)},
+		{"курсор старого формата", "cursor=Mg"},
+		{"не base64", "cursor=%21"},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		listFilesHandler(w, httptest.NewRequest(http.MethodGet, "/files?"+c.query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: код %d, ожидался 400", c.name, w.Code)
+		}
+	}
+}
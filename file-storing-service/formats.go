@@ -0,0 +1,32 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"shared/formats"
+)
+
+// loadFormats загружает общий реестр форматов и проверяет, что сервис умеет извлекать
+// текст из каждого формата.
+func loadFormats() {
+	formats.Load()
+	for _, f := range formats.All() {
+		_, isDocument := documentExtractors[f.Extractor]
+		if !f.IsSource() && !isDocument && !f.IsArchive() {
+			panic(fmt.Sprintf("Формат %s: неизвестный способ извлечения текста %q", f.Name, f.Extractor))
+		}
+	}
+}
+
+func formatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"formats": formats.All(),
+	})
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"html/template"
+	"strings"
+
+	"shared/formats"
+)
+
+// Ключевые слова языков для подсветки в предпросмотре. Язык берётся из реестра форматов.
+var keywords = map[string][]string{
+	"go": {"break", "case", "chan", "const", "continue", "default", "defer", "else", "fallthrough", "for",
+		"func", "go", "goto", "if", "import", "interface", "map", "package", "range", "return", "select",
+		"struct", "switch", "type", "var", "nil", "true", "false"},
+	"python": {"and", "as", "assert", "async", "await", "break", "class", "continue", "def", "del", "elif",
+		"else", "except", "finally", "for", "from", "global", "if", "import", "in", "is", "lambda",
+		"nonlocal", "not", "or", "pass", "raise", "return", "try", "while", "with", "yield", "None", "True", "False"},
+	"java": {"abstract", "boolean", "break", "byte", "case", "catch", "char", "class", "continue", "default",
+		"do", "double", "else", "enum", "extends", "final", "finally", "float", "for", "if", "implements",
+		"import", "instanceof", "int", "interface", "long", "new", "package", "private", "protected", "public",
+		"return", "short", "static", "super", "switch", "this", "throw", "throws", "try", "void", "while",
+		"null", "true", "false"},
+	"c": {"auto", "break", "case", "char", "const", "continue", "default", "do", "double", "else", "enum",
+		"extern", "float", "for", "goto", "if", "int", "long", "register", "return", "short", "signed",
+		"sizeof", "static", "struct", "switch", "typedef", "union", "unsigned", "void", "volatile", "while",
+		"#include", "#define", "NULL"},
+	"cpp": {"auto", "bool", "break", "case", "catch", "char", "class", "const", "continue", "default", "delete",
+		"do", "double", "else", "enum", "false", "float", "for", "if", "int", "long", "namespace", "new",
+		"nullptr", "private", "protected", "public", "return", "short", "sizeof", "static", "struct", "switch",
+		"template", "this", "throw", "true", "try", "typedef", "typename", "unsigned", "using", "virtual",
+		"void", "while", "#include", "#define"},
+	"javascript": {"async", "await", "break", "case", "catch", "class", "const", "continue", "default", "delete",
+		"do", "else", "export", "extends", "false", "finally", "for", "function", "if", "import", "in",
+		"instanceof", "let", "new", "null", "return", "super", "switch", "this", "throw", "true", "try",
+		"typeof", "undefined", "var", "void", "while", "yield"},
+	"typescript": {"any", "as", "async", "await", "boolean", "break", "case", "catch", "class", "const",
+		"continue", "default", "else", "enum", "export", "extends", "false", "for", "function", "if",
+		"implements", "import", "in", "interface", "let", "new", "null", "number", "private", "public",
+		"readonly", "return", "string", "switch", "this", "throw", "true", "try", "type", "undefined",
+		"var", "void", "while"},
+}
+
+var keywordSets = map[string]map[string]bool{}
+
+func init() {
+	for lang, words := range keywords {
+		set := map[string]bool{}
+		for _, w := range words {
+			set[w] = true
+		}
+		keywordSets[lang] = set
+	}
+}
+
+type highlightToken struct {
+	class string
+	text  string
+}
+
+// highlight размечает код по синтаксису формата (комментарии из реестра, строки, числа,
+// ключевые слова) и возвращает HTML построчно. Текст и документы выводятся без подсветки.
+func highlight(code string, format *formats.Format) []template.HTML {
+	if format == nil || format.Tokenizer == "text" {
+		return renderLines([]highlightToken{{text: code}})
+	}
+	var comments formats.CommentSyntax
+	if format.Comments != nil {
+		comments = *format.Comments
+	}
+	words := keywordSets[format.Language]
+	// Токены идут подряд, поэтому соседние токены одного класса склеиваются расширением
+	// среза code, а не конкатенацией строк
+	var tokens []highlightToken
+	start := 0
+	add := func(class string, from int, to int) {
+		if n := len(tokens); n > 0 && tokens[n-1].class == class {
+			tokens[n-1].text = code[start:to]
+			return
+		}
+		start = from
+		tokens = append(tokens, highlightToken{class: class, text: code[from:to]})
+	}
+	for i := 0; i < len(code); {
+		rest := code[i:]
+		if commentStart(rest, comments.Line) {
+			end := strings.IndexByte(rest, '\n')
+			if end == -1 {
+				end = len(rest)
+			}
+			add("com", i, i+end)
+			i += end
+			continue
+		}
+		if start, stop := blockComment(rest, comments.Block); start != "" {
+			end := strings.Index(rest[len(start):], stop)
+			if end == -1 {
+				end = len(rest)
+			} else {
+				end += len(start) + len(stop)
+			}
+			add("com", i, i+end)
+			i += end
+			continue
+		}
+		c := code[i]
+		switch {
+		case c == '"' || c == '\'' || c == '`':
+			j := i + 1
+			for j < len(code) {
+				if code[j] == '\\' {
+					j += 2
+					continue
+				}
+				if code[j] == c {
+					j++
+					break
+				}
+				if code[j] == '\n' && c != '`' {
+					break
+				}
+				j++
+			}
+			if j > len(code) {
+				j = len(code)
+			}
+			add("str", i, j)
+			i = j
+		case isDigit(c) && (i == 0 || !isIdentByte(code[i-1])):
+			j := i
+			for j < len(code) && (isIdentByte(code[j]) || code[j] == '.') {
+				j++
+			}
+			add("num", i, j)
+			i = j
+		case isIdentByte(c) || (c == '#' && !commentStart("#", comments.Line)):
+			j := i + 1
+			for j < len(code) && isIdentByte(code[j]) {
+				j++
+			}
+			if words[code[i:j]] {
+				add("kw", i, j)
+			} else {
+				add("", i, j)
+			}
+			i = j
+		default:
+			add("", i, i+1)
+			i++
+		}
+	}
+	return renderLines(tokens)
+}
+
+func commentStart(s string, markers []string) bool {
+	for _, m := range markers {
+		if m != "" && strings.HasPrefix(s, m) {
+			return true
+		}
+	}
+	return false
+}
+
+func blockComment(s string, blocks [][2]string) (string, string) {
+	for _, b := range blocks {
+		if b[0] != "" && b[1] != "" && strings.HasPrefix(s, b[0]) {
+			return b[0], b[1]
+		}
+	}
+	return "", ""
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// renderLines разбивает размеченный текст на строки: токен, который тянется через несколько
+// строк (блочный комментарий), закрывается и открывается заново на каждой строке.
+func renderLines(tokens []highlightToken) []template.HTML {
+	var lines []template.HTML
+	var sb strings.Builder
+	for _, t := range tokens {
+		for i, piece := range strings.Split(strings.ReplaceAll(t.text, "\r\n", "\n"), "\n") {
+			if i > 0 {
+				lines = append(lines, template.HTML(sb.String()))
+				sb.Reset()
+			}
+			if piece == "" {
+				continue
+			}
+			escaped := template.HTMLEscapeString(piece)
+			if t.class == "" {
+				sb.WriteString(escaped)
+			} else {
+				sb.WriteString(`<span class="` + t.class + `">` + escaped + `</span>`)
+			}
+		}
+	}
+	if sb.Len() > 0 {
+		lines = append(lines, template.HTML(sb.String()))
+	}
+	return lines
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+	"time"
+
+	"shared/formats"
+)
+
+var testPython = &formats.Format{Name: "python", Language: "python", Tokenizer: "code",
+	Comments: &formats.CommentSyntax{Line: []string{"#"}}}
+
+var testJava = &formats.Format{Name: "java", Language: "java", Tokenizer: "code",
+	Comments: &formats.CommentSyntax{Line: []string{"//"}, Block: [][2]string{{"/*", "*/"}}}}
+
+func TestHighlight(t *testing.T) {
+	cases := []struct {
+		name   string
+		code   string
+		format *formats.Format
+		want   []template.HTML
+	}{
+		{
+			name:   "ключевые слова, строки, числа и комментарий",
+			code:   "def f(x):\n    return x + 42  # ответ\ns = 'a<b'",
+			format: testPython,
+			want: []template.HTML{
+				`<span class="kw">def</span> f(x):`,
+				`    <span class="kw">return</span> x + <span class="num">42</span>  <span class="com"># ответ</span>`,
+				`s = <span class="str">&#39;a&lt;b&#39;</span>`,
+			},
+		},
+		{
+			name:   "блочный комментарий на несколько строк",
+			code:   "int a; /* one\ntwo */ int b;",
+			format: testJava,
+			want: []template.HTML{
+				`<span class="kw">int</span> a; <span class="com">/* one</span>`,
+				`<span class="com">two */</span> <span class="kw">int</span> b;`,
+			},
+		},
+		{
+			name: "текст без подсветки",
+			code: "if <b>\nreturn",
+			want: []template.HTML{`if &lt;b&gt;`, `return`},
+		},
+	}
+	for _, c := range cases {
+		got := highlight(c.code, c.format)
+		if strings.Join(htmlStrings(got), "\n") != strings.Join(htmlStrings(c.want), "\n") {
+			t.Errorf("%s:\nполучено %q\nожидалось %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestHighlightLargeFile(t *testing.T) {
+	// Склейка токенов конкатенацией строк была квадратичной: 1 МБ кода без ключевых слов,
+	// строк и чисел — один длинный токен — подсвечивался минутами
+	code := strings.Repeat("total = total + value * weight\n", (1<<20)/31)
+	start := time.Now()
+	lines := highlight(code, testPython)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("подсветка 1 МБ заняла %v", elapsed)
+	}
+	if len(lines) != strings.Count(code, "\n") {
+		t.Fatalf("строк %d, ожидалось %d", len(lines), strings.Count(code, "\n"))
+	}
+}
+
+func htmlStrings(lines []template.HTML) []string {
+	out := make([]string, len(lines))
+	for i, l := range lines {
+		out[i] = string(l)
+	}
+	return out
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Внутренний API для сервиса анализа. Через gateway он недоступен: сервис анализа не ходит
+// ни в БД, ни в хранилище блобов, а получает работы и их текст только отсюда.
+//
+//	GET  /internal/submissions?assignment_id=…  — работы задания (current=true — только текущие версии)
+//	GET  /internal/students?group_id=…          — студенты группы
+//	GET  /internal/files/{id}                   — сведения о работе и список файлов проекта
+//	GET  /internal/files/{id}/text[?member=…]   — UTF-8 текст работы или файла проекта
+//	POST /internal/files/{id}/status            — смена статуса анализа
+
+// Submission — то, что сервису анализа нужно знать о работе.
+type Submission struct {
+	ID            int64    `json:"id"`
+	StudentID     string   `json:"student_id"`
+	AssignmentID  string   `json:"assignment_id"`
+	Filename      string   `json:"filename"`
+	TextPath      string   `json:"text_path"`
+	ContentCheck  string   `json:"content_check"`
+	ContentReason string   `json:"content_reason"`
+	Members       []string `json:"members,omitempty"`
+}
+
+const submissionColumns = `id, student_id, assignment_id, COALESCE(original_filename, ''), COALESCE(text_path, file_path),
+	COALESCE(content_check, 'text'), COALESCE(content_reason, '')`
+
+func scanSubmission(row rowScanner) (Submission, error) {
+	var s Submission
+	err := row.Scan(&s.ID, &s.StudentID, &s.AssignmentID, &s.Filename, &s.TextPath, &s.ContentCheck, &s.ContentReason)
+	return s, err
+}
+
+func internalFileHandler(w http.ResponseWriter, r *http.Request) {
+	id, sub, _ := strings.Cut(r.URL.Path[len("/internal/files/"):], "/")
+	switch sub {
+	case "":
+		submissionHandler(w, r, id)
+	case "text":
+		submissionTextHandler(w, r, id)
+	case "status":
+		fileStatusHandler(w, r, id)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func submissionHandler(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s, err := scanSubmission(db.QueryRow(`SELECT `+submissionColumns+` FROM files WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		http.Error(w, `Файл не найден`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
+	s.Members, err = loadMembers(id)
+	if err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s)
+}
+
+// submissionTextHandler отдаёт текст, по которому идёт анализ. У проекта текст отдаётся
+// по одному файлу: ?member=путь из списка members.
+func submissionTextHandler(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	f, ok := loadStoredFileOrError(w, id)
+	if !ok {
+		return
+	}
+	key := f.TextPath
+	if member := r.URL.Query().Get("member"); member != "" {
+		members, err := loadMembers(id)
+		if err != nil {
+			http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+			return
+		}
+		found := false
+		for _, m := range members {
+			found = found || m == member
+		}
+		if !found {
+			http.Error(w, `Файл проекта не найден`, http.StatusNotFound)
+			return
+		}
+		key += "/" + member
+	}
+	rc, err := storage.Open(key)
+	if err != nil {
+		previewReadError(w, err)
+		return
+	}
+	defer rc.Close()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if _, err := io.Copy(w, rc); err != nil {
+		fmt.Println("Ошибка при отправке текста работы:", err)
+	}
+}
+
+// submissionsHandler возвращает работы задания в порядке загрузки: все или только текущие
+// версии сдач.
+func submissionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	assignmentID := r.URL.Query().Get("assignment_id")
+	if assignmentID == "" {
+		http.Error(w, `assignment_id обязателен`, http.StatusBadRequest)
+		return
+	}
+	query := `SELECT ` + submissionColumns + ` FROM files WHERE assignment_id = ?`
+	if r.URL.Query().Get("current") == "true" {
+		query += ` AND ` + currentVersionCond
+	}
+	rows, err := db.Query(query+` ORDER BY id ASC`, assignmentID)
+	if err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	submissions := []Submission{}
+	for rows.Next() {
+		s, err := scanSubmission(rows)
+		if err != nil {
+			continue
+		}
+		submissions = append(submissions, s)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"submissions": submissions,
+	})
+}
+
+// groupStudentsHandler возвращает id студентов группы: по ним сервис анализа фильтрует отчёты.
+func groupStudentsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	groupID := r.URL.Query().Get("group_id")
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM student_groups WHERE id = ?`, groupID).Scan(&n); err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		http.Error(w, `Группа не найдена`, http.StatusNotFound)
+		return
+	}
+	students, err := queryStudents(`SELECT id, name, group_id FROM students WHERE group_id = ? ORDER BY id ASC`, groupID)
+	if err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
+	ids := []string{}
+	for _, s := range students {
+		ids = append(ids, s.ID)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"students": ids,
+	})
+}
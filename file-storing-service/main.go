@@ -3,15 +3,21 @@ package main
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	_ "github.com/glebarez/go-sqlite"
+
+	"shared/formats"
+	"shared/pagination"
 )
 
 type FileInfo struct {
@@ -21,13 +27,46 @@ type FileInfo struct {
 	FilePath     string      `json:"file_path"`
 	UploadedAt   interface{} `json:"uploaded_at"`
 	Status       string      `json:"status"`
+	// Encoding — исходная кодировка работы, TextPath — её UTF-8 копия для анализа
+	// (совпадает с FilePath, если работа уже в UTF-8).
+	Encoding string `json:"encoding"`
+	TextPath string `json:"text_path"`
+	// ContentCheck — результат проверки содержимого: text или binary (такие работы не анализируются).
+	ContentCheck string `json:"content_check"`
+	// SHA256 и Size — хэш и размер загруженного файла; по хэшу работа лежит в хранилище блобов.
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+	// OriginalFilename, MIME и Language — имя файла при загрузке и его тип по реестру форматов,
+	// LineCount — число строк текста работы, Comment — комментарий студента к работе.
+	OriginalFilename string `json:"original_filename"`
+	MIME             string `json:"mime"`
+	Language         string `json:"language"`
+	LineCount        int    `json:"line_count"`
+	Comment          string `json:"comment"`
+	// QueuedAt, AnalysisStartedAt и FinishedAt — время переходов по статусам анализа
+	// (null, если работа ещё не доходила до этого статуса).
+	QueuedAt          interface{} `json:"queued_at"`
+	AnalysisStartedAt interface{} `json:"analysis_started_at"`
+	FinishedAt        interface{} `json:"finished_at"`
+	// Late — работа сдана после срока задания (closes_at).
+	Late bool `json:"late"`
+	// Version — номер версии среди работ студента по заданию, Final — версия отмечена
+	// итоговой, Current — это текущая версия сдачи (итоговая или, если её нет, последняя).
+	Version int  `json:"version"`
+	Final   bool `json:"final"`
+	Current bool `json:"current"`
+	// Members — файлы проекта, если работа загружена архивом.
+	Members []string `json:"members,omitempty"`
 }
 
 var db *sql.DB
 
-func init() {
+// openDB подключается к БД сервиса. Транзакции сразу берут блокировку на запись, а
+// остальные ждут её освобождения: так проверка квоты и запись работы не перемежаются
+// с параллельной загрузкой.
+func openDB(path string) {
 	var err error
-	db, err = sql.Open("sqlite", "/app/files.db")
+	db, err = sql.Open("sqlite", path+"?_txlock=immediate&_pragma=busy_timeout(10000)")
 	if err != nil {
 		panic("Ошибка подключения к БД: " + err.Error())
 	}
@@ -36,32 +75,89 @@ func init() {
 		panic("БД не отвечает: " + err.Error())
 	}
 	fmt.Println("file-storing-service подключен к БД")
-	createTable()
 }
-func createTable() {
-	query := `
-	CREATE TABLE IF NOT EXISTS files (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		student_id TEXT NOT NULL,
-		assignment_id TEXT NOT NULL,
-		file_path TEXT NOT NULL,
-		uploaded_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		status TEXT DEFAULT 'pending'    
-	)
-	`
-	_, err := db.Exec(query)
+
+// saveMembers сохраняет список файлов проекта, распакованного из архива.
+func saveMembers(tx *sql.Tx, fileID int64, projectKey string, members []string, encodings map[string]string) error {
+	for _, member := range members {
+		query := `
+		INSERT INTO submission_files (file_id, member_path, file_path, encoding)
+		VALUES (?, ?, ?, ?)
+		`
+		_, err := tx.Exec(query, fileID, member, projectKey+"/"+member, encodings[member])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadMembers(fileID string) ([]string, error) {
+	rows, err := db.Query(`SELECT member_path FROM submission_files WHERE file_id = ? ORDER BY id ASC`, fileID)
 	if err != nil {
-		panic("Ошибка создания таблицы: " + err.Error())
+		return nil, err
 	}
-	fmt.Println("Таблица для файлов готова к использованию")
+	defer rows.Close()
+	var members []string
+	for rows.Next() {
+		var member string
+		if err := rows.Scan(&member); err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	return members, rows.Err()
+}
+
+// setupDB применяет недостающие миграции схемы и дозаполняет данные работ, загруженных
+// старыми версиями сервиса.
+func setupDB() {
+	if _, err := migrateUp(0); err != nil {
+		panic("Ошибка миграции БД: " + err.Error())
+	}
+	fmt.Println("Схема БД актуальна")
+	backfillAssignments()
+	backfillCourses()
+	backfillContentCheck()
+	backfillHashes()
+	backfillNormalizedHashes()
+	backfillStatuses()
+	backfillVersions()
 }
 
 func main() {
+	openDB("/app/files.db")
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+	loadFormats()
+	storage = openStorage()
+	if s3, ok := storage.(*s3Storage); ok {
+		if err := s3.ensureBucket(); err != nil {
+			panic("Бакет S3 недоступен: " + err.Error())
+		}
+	}
+	setupDB()
+	go runTusExpiration()
 	os.MkdirAll("/app/uploads", 0755)
 	http.HandleFunc("/health", healthHandler)
 	http.HandleFunc("/upload", uploadHandler)
+	http.HandleFunc("/uploads", tusHandler)
+	http.HandleFunc("/uploads/", tusHandler)
 	http.HandleFunc("/files", listFilesHandler)
 	http.HandleFunc("/files/", getFileHandler)
+	http.HandleFunc("/formats", formatsHandler)
+	http.HandleFunc("/students/", studentsHandler)
+	http.HandleFunc("/courses", coursesHandler)
+	http.HandleFunc("/courses/", courseHandler)
+	http.HandleFunc("/groups", groupsHandler)
+	http.HandleFunc("/groups/", groupHandler)
+	http.HandleFunc("/assignments", assignmentsHandler)
+	http.HandleFunc("/assignments/", assignmentHandler)
+	http.HandleFunc("/internal/files/", internalFileHandler)
+	http.HandleFunc("/internal/submissions", submissionsHandler)
+	http.HandleFunc("/internal/students", groupStudentsHandler)
 
 	fmt.Println("File Storing Service запущен на http://localhost:8082")
 	http.ListenAndServe(":8082", nil)
@@ -83,118 +179,346 @@ func uploadHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	w.Header().Set("Content-Type", "application/json")
 
-	err := r.ParseMultipartForm(15 << 20)
-	if err != nil {
-		http.Error(w, `Ошибка при парсинге формы`, http.StatusBadRequest)
+	u, cleanup, uerr := receiveUpload(w, r)
+	defer cleanup()
+	if uerr != nil {
+		// Остаток тела не дочитывается: соединение закрывается после ответа
+		w.Header().Set("Connection", "close")
+		uerr.write(w)
 		return
 	}
-	studentID := r.FormValue("student_id")
-	assignmentID := r.FormValue("assignment_id")
-	if studentID == "" || assignmentID == "" {
-		http.Error(w, `student_id и assignment_id обязательны`, http.StatusBadRequest)
+	if uerr := u.validate(); uerr != nil {
+		uerr.write(w)
 		return
 	}
-	file, handler, err := r.FormFile("file")
-	if err != nil {
-		http.Error(w, `{"error": "Файл не найден в запросе"}`, http.StatusBadRequest)
+	response, uerr := storeUpload(u)
+	if uerr != nil {
+		uerr.write(w)
 		return
 	}
-	defer file.Close()
+	json.NewEncoder(w).Encode(response)
+}
 
-	ext := filepath.Ext(handler.Filename)
-	supportedExts := map[string]bool{
-		".txt":  true,
-		".go":   true,
-		".py":   true,
-		".js":   true,
-		".java": true,
-		".cpp":  true,
-		".c":    true,
-		".h":    true,
-		".ts":   true,
-		".md":   true,
-	}
-	if !supportedExts[strings.ToLower(ext)] {
-		http.Error(w, fmt.Sprintf(`Формат %s не поддерживается. Разрешены: txt, go, py, java, cpp, c, h, js, ts, md`, ext), http.StatusUnsupportedMediaType)
+// upload — работа, которую нужно зарегистрировать: файл из формы /upload или собранный
+// из частей загрузки tus.
+type upload struct {
+	file     uploadFile
+	filename string
+	size     int64
+	// hash — SHA-256 файла, если он посчитан при получении; иначе считается при сохранении.
+	hash         string
+	studentID    string
+	assignmentID string
+	comment      string
+	// submittedAt — когда работу начали сдавать: начало запроса /upload или создание
+	// загрузки tus. По нему работа считается опоздавшей.
+	submittedAt time.Time
+	// tusID — загрузка tus, из которой получена работа: её резерв в квоте заменяется работой.
+	tusID string
+}
+
+type uploadFile interface {
+	io.ReadSeeker
+	io.ReaderAt
+}
+
+// uploadError — отказ в приёме работы: код ответа и текст ошибки или JSON-тело.
+type uploadError struct {
+	code    int
+	message string
+	body    map[string]interface{}
+}
+
+func (e *uploadError) write(w http.ResponseWriter) {
+	if e.body != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(e.code)
+		json.NewEncoder(w).Encode(e.body)
 		return
 	}
-	timestamp := time.Now().Unix()
-	// Создаём имя формата: work_student001_task1_1732874940.txt
-	safeFilename := fmt.Sprintf("work_%s_%s_%d%s", studentID, assignmentID, timestamp, ext)
+	http.Error(w, e.message, e.code)
+}
 
-	uploadsDir := "/app/uploads"
-	if _, err := os.Stat(uploadsDir); os.IsNotExist(err) {
-		uploadsDir = "./uploads"
+// validate проверяет то, что известно до получения содержимого: студента и его запись на
+// курс, задание и его сроки, комментарий и формат по имени файла.
+func (u upload) validate() *uploadError {
+	if u.studentID == "" || u.assignmentID == "" {
+		return &uploadError{code: http.StatusBadRequest, message: `student_id и assignment_id обязательны`}
 	}
+	if utf8.RuneCountInString(u.comment) > maxCommentLength {
+		return &uploadError{code: http.StatusBadRequest, message: fmt.Sprintf(`Комментарий длиннее %d символов`, maxCommentLength)}
+	}
+	if formats.For(u.filename) == nil {
+		message := fmt.Sprintf(`Формат %s не поддерживается. Разрешены: %s`, filepath.Ext(u.filename), formats.ExtensionList(false))
+		return &uploadError{code: http.StatusUnsupportedMediaType, message: message}
+	}
+	_, uerr := checkAssignment(u.assignmentID, u.studentID, u.filename, u.submittedAt)
+	return uerr
+}
 
-	abspath, err := filepath.Abs(filepath.Join(uploadsDir, safeFilename))
+// storeUpload сохраняет работу в хранилище и регистрирует её в БД. Возвращает тело ответа
+// на загрузку или отказ.
+func storeUpload(u upload) (map[string]interface{}, *uploadError) {
+	ext := filepath.Ext(u.filename)
+	format := formats.For(u.filename)
+	if format == nil {
+		return nil, &uploadError{code: http.StatusUnsupportedMediaType, message: fmt.Sprintf(`Формат %s не поддерживается. Разрешены: %s`, ext, formats.ExtensionList(false))}
+	}
+	archive := ""
+	if format.IsArchive() {
+		archive = format.Extractor
+	}
+	extractText, isDocument := documentExtractors[format.Extractor]
+	// Задание проверяется ещё раз: загрузку tus могли завершить после его изменения
+	late, uerr := checkAssignment(u.assignmentID, u.studentID, u.filename, u.submittedAt)
+	if uerr != nil {
+		return nil, uerr
+	}
+	hash, size := u.hash, u.size
+	if hash == "" {
+		var err error
+		hash, size, err = hashUpload(u.file)
+		if err != nil {
+			fmt.Println("Ошибка при чтении файла:", err)
+			return nil, &uploadError{code: http.StatusInternalServerError, message: `Ошибка при чтении файла`}
+		}
+	}
+	// Архив распаковывается в каталог, названный по хэшу архива, без расширения
+	blobExt := ext
+	if archive != "" {
+		blobExt = ""
+	}
+	key := blobKey(hash, blobExt)
+	work, err := os.MkdirTemp("", "upload-*")
 	if err != nil {
-		fmt.Println("Ошибка при получении абсолютного пути:", err)
-		http.Error(w, `Ошибка при получении пути`, http.StatusInternalServerError)
-		return
+		fmt.Println("Ошибка при создании рабочего каталога:", err)
+		return nil, &uploadError{code: http.StatusInternalServerError, message: `Ошибка при получении пути`}
 	}
-	fmt.Println("Пытаемся создать файл:", abspath)
+	defer os.RemoveAll(work)
+	abspath := filepath.Join(work, filepath.FromSlash(key))
 
-	dst, err := os.Create(abspath)
+	var members []string
+	var rejected []RejectedMember
+	var memberEncodings map[string]string
+	var encoding, textPath string
+	contentCheck, contentReason := contentText, ""
+	deduplicated := false
+	if archive != "" {
+		// Архив распаковывается и тогда, когда такой же уже загружали: результат распаковки
+		// (members, rejected) нужен для ответа
+		if err := os.MkdirAll(abspath, 0755); err != nil {
+			fmt.Println("Ошибка при создании каталога:", err)
+			return nil, &uploadError{code: http.StatusInternalServerError, message: `Ошибка при создании каталога проекта`}
+		}
+		fmt.Println("Распаковываем архив в:", abspath)
+		extracted, err := extractArchive(u.file, u.size, archive, abspath)
+		if errors.Is(err, errArchiveLimit) {
+			fmt.Println("Архив отклонён:", err)
+			return nil, &uploadError{code: http.StatusRequestEntityTooLarge, message: fmt.Sprintf(`Архив отклонён: %v`, err)}
+		}
+		if err != nil {
+			fmt.Println("Ошибка при распаковке архива:", err)
+			return nil, &uploadError{code: http.StatusBadRequest, message: `Ошибка при распаковке архива`}
+		}
+		members, rejected = extracted.Members, extracted.Rejected
+		if len(members) == 0 {
+			return nil, &uploadError{code: http.StatusBadRequest, body: map[string]interface{}{
+				"error":    "В архиве нет файлов поддерживаемых форматов",
+				"rejected": rejected,
+			}}
+		}
+		memberEncodings, encoding, textPath, err = normalizeProject(abspath, members)
+		if err != nil {
+			fmt.Println("Ошибка при перекодировании проекта:", err)
+			return nil, &uploadError{code: http.StatusInternalServerError, message: `Ошибка при определении кодировки`}
+		}
+	} else if isDocument {
+		text, err := extractText(u.file, u.size)
+		if errors.Is(err, errNoText) {
+			// Работа принимается, но сервис анализа пропустит её с явным состоянием вместо 0%
+			fmt.Printf("В документе %s нет текста, анализ будет пропущен\n", u.filename)
+			contentCheck, contentReason, err = contentNoText, err.Error(), nil
+		}
+		if errors.Is(err, errArchiveLimit) {
+			return nil, &uploadError{code: http.StatusRequestEntityTooLarge, message: fmt.Sprintf(`Документ отклонён: %v`, err)}
+		}
+		if err != nil {
+			fmt.Printf("Не удалось извлечь текст из %s: %v\n", u.filename, err)
+			return nil, &uploadError{code: http.StatusUnsupportedMediaType, message: fmt.Sprintf(`Не удалось извлечь текст из документа (%v)`, err)}
+		}
+		if err := saveWorkFile(u.file, abspath); err != nil {
+			fmt.Println("Ошибка при сохранении файла:", err)
+			return nil, &uploadError{code: http.StatusInternalServerError, message: `Ошибка при сохранении файла`}
+		}
+		encoding = encodingUTF8
+		textPath = abspath + ".txt"
+		if err := os.WriteFile(textPath, []byte(text), 0644); err != nil {
+			fmt.Println("Ошибка при сохранении текста документа:", err)
+			return nil, &uploadError{code: http.StatusInternalServerError, message: `Ошибка при сохранении текста документа`}
+		}
+	} else {
+		head := make([]byte, sniffSize)
+		n, _ := io.ReadFull(u.file, head)
+		if reason := sniffContent(head[:n]); reason != "" {
+			fmt.Printf("Файл %s отклонён: %s\n", u.filename, reason)
+			return nil, &uploadError{code: http.StatusUnsupportedMediaType, message: fmt.Sprintf(`Содержимое файла не похоже на текст (%s)`, reason)}
+		}
+		if err := saveWorkFile(u.file, abspath); err != nil {
+			fmt.Println("Ошибка при сохранении файла:", err)
+			return nil, &uploadError{code: http.StatusInternalServerError, message: `Ошибка при сохранении файла`}
+		}
+		encoding, textPath, err = normalizeFile(abspath, textFilePath(abspath))
+		if err != nil {
+			fmt.Println("Ошибка при перекодировании файла:", err)
+			return nil, &uploadError{code: http.StatusInternalServerError, message: `Ошибка при определении кодировки`}
+		}
+	}
+	primary := key
+	if archive != "" {
+		primary = key + "/" + members[0]
+	}
+	deduplicated, err = storage.Exists(primary)
+	if err == nil && !deduplicated {
+		fmt.Println("Сохраняем работу в хранилище:", key)
+		err = publishWork(work, primary)
+	}
 	if err != nil {
-		fmt.Println("Ошибка при создании файла:", err)
-		http.Error(w, `Ошибка при создании файла`, http.StatusInternalServerError)
-		return
+		fmt.Println("Ошибка при сохранении в хранилище:", err)
+		return nil, &uploadError{code: http.StatusInternalServerError, message: `Ошибка при сохранении файла`}
 	}
-	defer dst.Close()
-	_, err = io.Copy(dst, file)
+	textKey, err := workKey(work, textPath)
 	if err != nil {
-		http.Error(w, `Ошибка при копировании файла`, http.StatusInternalServerError)
-		return
+		return nil, &uploadError{code: http.StatusInternalServerError, message: `Ошибка при получении пути`}
+	}
+	if encoding != encodingUTF8 {
+		fmt.Printf("Кодировка %s, текст в UTF-8 сохранён в %s\n", encoding, textKey)
+	}
+	// Точные копии видны сразу, не дожидаясь асинхронного анализа
+	normalized, err := normalizedHash(textPath, members)
+	if err != nil {
+		fmt.Println("Ошибка при подсчёте нормализованного хэша:", err)
+	}
+	duplicates, err := findDuplicates(u.studentID, u.assignmentID, hash, normalized)
+	if err != nil {
+		fmt.Println("Ошибка при поиске копий:", err)
+	}
+	if len(duplicates.Exact)+len(duplicates.Normalized) > 0 {
+		fmt.Printf("Найдены копии работы: точные %v, с точностью до пробелов %v\n", duplicates.Exact, duplicates.Normalized)
+	}
+	originalFilename := filepath.Base(u.filename)
+	language := format.Language
+	if archive != "" {
+		language = projectLanguage(members)
+	}
+	lineCount, err := countLines(textPath, members)
+	if err != nil {
+		fmt.Println("Ошибка при подсчёте строк:", err)
 	}
 	query := `
-	INSERT INTO files (student_id, assignment_id, file_path, status)
-	VALUES (?, ?, ?, 'pending')
+	INSERT INTO files (student_id, assignment_id, file_path, status, encoding, text_path, content_check, content_reason, sha256, size, normalized_sha256,
+		original_filename, mime, language, line_count, comment, late, version)
+	VALUES (?, ?, ?, 'uploaded', ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ` + nextVersion + `)
 	`
-	result, err := db.Exec(query, studentID, assignmentID, abspath)
+	tx, err := db.Begin()
 	if err != nil {
-		http.Error(w, `Ошибка при сохранении данных в БД`, http.StatusBadRequest)
-		return
+		return nil, &uploadError{code: http.StatusInternalServerError, message: `Ошибка при запросе к БД`}
+	}
+	defer tx.Rollback()
+	// Квота проверяется в той же транзакции, что и запись работы: иначе параллельные
+	// загрузки, прошедшие проверку одновременно, вместе превысили бы квоту
+	if uerr := checkQuota(tx, u.studentID, u.assignmentID, u.tusID, size); uerr != nil {
+		return nil, uerr
+	}
+	result, err := tx.Exec(query, u.studentID, u.assignmentID, key, encoding, textKey, contentCheck, contentReason, hash, size, normalized,
+		originalFilename, format.MIME, language, lineCount, u.comment, late, u.studentID, u.assignmentID)
+	if err != nil {
+		return nil, &uploadError{code: http.StatusBadRequest, message: `Ошибка при сохранении данных в БД`}
 	}
 
 	fileID, err := result.LastInsertId()
 	if err != nil {
-		http.Error(w, `Ошибка при получении ID`, http.StatusInternalServerError)
-		return
+		return nil, &uploadError{code: http.StatusInternalServerError, message: `Ошибка при получении ID`}
+	}
+	if err := saveMembers(tx, fileID, key, members, memberEncodings); err != nil {
+		return nil, &uploadError{code: http.StatusInternalServerError, message: `Ошибка при сохранении файлов проекта в БД`}
+	}
+	if u.tusID != "" {
+		if _, err := tx.Exec(`UPDATE tus_uploads SET file_id = ? WHERE id = ?`, fileID, u.tusID); err != nil {
+			return nil, &uploadError{code: http.StatusInternalServerError, message: `Ошибка при сохранении данных в БД`}
+		}
+	}
+	var version int
+	if err := tx.QueryRow(`SELECT version FROM files WHERE id = ?`, fileID).Scan(&version); err != nil {
+		fmt.Println("Ошибка при получении версии работы:", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, &uploadError{code: http.StatusInternalServerError, message: `Ошибка при сохранении данных в БД`}
 	}
 
 	response := map[string]interface{}{
 		"status":        "success",
 		"message":       "Файл получен, работа зарегистрирована",
 		"file_id":       fileID,
-		"student_id":    studentID,
-		"assignment_id": assignmentID,
-		"filename":      handler.Filename,
-		"file_path":     abspath,
+		"student_id":    u.studentID,
+		"assignment_id": u.assignmentID,
+		"filename":      originalFilename,
+		"mime":          format.MIME,
+		"language":      language,
+		"line_count":    lineCount,
+		"comment":       u.comment,
+		"file_path":     key,
+		"text_path":     textKey,
+		"encoding":      encoding,
+		"content_check": contentCheck,
+		"sha256":        hash,
+		"size":          size,
+		"deduplicated":  deduplicated,
+		"duplicates":    duplicates,
+		"late":          late,
+		"version":       version,
 	}
-	json.NewEncoder(w).Encode(response)
+	if contentReason != "" {
+		response["content_reason"] = contentReason
+	}
+	if archive != "" {
+		response["members"] = members
+		response["rejected"] = rejected
+	}
+	return response, nil
 }
 
 func getFileHandler(w http.ResponseWriter, r *http.Request) {
+	file_id := r.URL.Path[len("/files/"):]
+	if id, sub, ok := strings.Cut(file_id, "/"); ok {
+		switch sub {
+		case "final":
+			fileFinalHandler(w, r, id)
+			return
+		case "versions":
+			fileVersionsHandler(w, r, id)
+			return
+		}
+		if r.Method != http.MethodGet {
+			http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		switch sub {
+		case "content":
+			fileContentHandler(w, r, id)
+		case "preview":
+			filePreviewHandler(w, r, id)
+		default:
+			http.NotFound(w, r)
+		}
+		return
+	}
 	if r.Method != http.MethodGet {
 		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	file_id := r.URL.Path[len("/files/"):]
-	query := `
-	SELECT id, student_id, assignment_id, file_path, uploaded_at, status FROM files WHERE id = ?
-	`
-	row := db.QueryRow(query, file_id)
-	var file FileInfo
-	err := row.Scan(
-		&file.ID,
-		&file.StudentID,
-		&file.AssignmentID,
-		&file.FilePath,
-		&file.UploadedAt,
-		&file.Status,
-	)
+	query := `SELECT ` + fileColumns + ` FROM files WHERE id = ?`
+	file, err := scanFileInfo(db.QueryRow(query, file_id))
 	if err == sql.ErrNoRows {
 		http.Error(w, `Файл не найден`, http.StatusNotFound)
 		return
@@ -203,6 +527,11 @@ func getFileHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
 		return
 	}
+	file.Members, err = loadMembers(file.ID)
+	if err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
 	json.NewEncoder(w).Encode(file)
 }
 
@@ -213,8 +542,51 @@ func listFilesHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	w.Header().Set("Content-Type", "application/json")
 
-	query := `SELECT id, student_id, assignment_id, file_path, uploaded_at, status FROM files`
-	rows, err := db.Query(query)
+	q := r.URL.Query()
+	params, err := pagination.ParseParams(q, fileSortFields, "id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var filters pagination.Filters
+	if v := q.Get("student_id"); v != "" {
+		filters.Add("student_id = ?", v)
+	}
+	if v := q.Get("assignment_id"); v != "" {
+		filters.Add("assignment_id = ?", v)
+	}
+	if v := q.Get("status"); v != "" {
+		filters.Add("status = ?", v)
+	}
+	if v := q.Get("group_id"); v != "" {
+		filters.Add("student_id IN (SELECT id FROM students WHERE group_id = ?)", v)
+	}
+	switch q.Get("current") {
+	case "true":
+		filters.Add(currentVersionCond)
+	case "false":
+		filters.Add("NOT " + currentVersionCond)
+	}
+	switch q.Get("late") {
+	case "true":
+		filters.Add("late = 1")
+	case "false":
+		filters.Add("late = 0")
+	}
+	if err := filters.AddTimeRange(q, "uploaded_at", "uploaded_from", "uploaded_to"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var total int
+	err = db.QueryRow(`SELECT COUNT(*) FROM files`+filters.Where(), filters.Args...).Scan(&total)
+	if err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
+	filters.AddCursor(params, "id")
+	query := `SELECT ` + fileColumns + ` FROM files` + filters.Where() + params.OrderBy("id") + ` LIMIT ?`
+	rows, err := db.Query(query, append(filters.Args, params.Limit+1)...)
 	if err != nil {
 		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
 		return
@@ -222,19 +594,76 @@ func listFilesHandler(w http.ResponseWriter, r *http.Request) {
 	defer rows.Close()
 	files := []FileInfo{}
 	for rows.Next() {
-		var file FileInfo
-		err := rows.Scan(
-			&file.ID,
-			&file.StudentID,
-			&file.AssignmentID,
-			&file.FilePath,
-			&file.UploadedAt,
-			&file.Status,
-		)
+		file, err := scanFileInfo(rows)
 		if err != nil {
 			continue
 		}
 		files = append(files, file)
 	}
-	json.NewEncoder(w).Encode(files)
+	nextCursor := ""
+	if len(files) > params.Limit {
+		files = files[:params.Limit]
+		id, _ := strconv.ParseInt(files[len(files)-1].ID, 10, 64)
+		nextCursor, err = params.NextCursor(db, "files", "id", id)
+		if err != nil {
+			http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+			return
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"files":       files,
+		"total":       total,
+		"next_cursor": nextCursor,
+	})
+}
+
+// fileSortFields — поля, по которым можно сортировать список работ.
+var fileSortFields = map[string]string{
+	"id":            "id",
+	"uploaded_at":   "uploaded_at",
+	"student_id":    "student_id",
+	"assignment_id": "assignment_id",
+	"size":          "COALESCE(size, 0)",
+	"line_count":    "COALESCE(line_count, 0)",
+}
+
+// fileColumns — колонки files в том порядке, в котором их читает scanFileInfo.
+const fileColumns = `id, student_id, assignment_id, file_path, uploaded_at, status, encoding,
+	COALESCE(text_path, file_path), COALESCE(content_check, 'text'), COALESCE(sha256, ''), COALESCE(size, 0),
+	COALESCE(original_filename, ''), COALESCE(mime, ''), COALESCE(language, ''), COALESCE(line_count, 0), COALESCE(comment, ''),
+	queued_at, analysis_started_at, finished_at, late, COALESCE(version, 1), is_final, ` + currentVersionCond
+
+// rowScanner — общее у *sql.Row и *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanFileInfo(row rowScanner) (FileInfo, error) {
+	var file FileInfo
+	err := row.Scan(
+		&file.ID,
+		&file.StudentID,
+		&file.AssignmentID,
+		&file.FilePath,
+		&file.UploadedAt,
+		&file.Status,
+		&file.Encoding,
+		&file.TextPath,
+		&file.ContentCheck,
+		&file.SHA256,
+		&file.Size,
+		&file.OriginalFilename,
+		&file.MIME,
+		&file.Language,
+		&file.LineCount,
+		&file.Comment,
+		&file.QueuedAt,
+		&file.AnalysisStartedAt,
+		&file.FinishedAt,
+		&file.Late,
+		&file.Version,
+		&file.Final,
+		&file.Current,
+	)
+	return file, err
 }
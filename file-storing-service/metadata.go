@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+
+	"shared/formats"
+)
+
+// maxCommentLength — сколько символов можно написать в комментарии к работе.
+const maxCommentLength = 1000
+
+// countLines считает строки текста работы по UTF-8 копии; для проекта — сумму по всем файлам.
+// Последняя строка без перевода строки тоже считается.
+func countLines(textPath string, members []string) (int, error) {
+	paths := []string{textPath}
+	if members != nil {
+		paths = paths[:0]
+		for _, m := range members {
+			paths = append(paths, filepath.Join(textPath, filepath.FromSlash(m)))
+		}
+	}
+	total := 0
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return total, err
+		}
+		total += bytes.Count(data, []byte{'\n'})
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			total++
+		}
+	}
+	return total, nil
+}
+
+// projectLanguage возвращает язык проекта: общий язык файлов с кодом или mixed.
+// Текстовые файлы (README, заметки) на язык проекта не влияют.
+func projectLanguage(members []string) string {
+	language := ""
+	for _, m := range members {
+		f := formats.For(m)
+		if f == nil || f.Tokenizer == "text" || f.Language == "" {
+			continue
+		}
+		if language == "" {
+			language = f.Language
+		} else if language != f.Language {
+			return "mixed"
+		}
+	}
+	if language == "" {
+		return "text"
+	}
+	return language
+}
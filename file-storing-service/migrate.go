@@ -0,0 +1,63 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+
+	"shared/migrate"
+)
+
+// Схема БД задаётся миграциями из migrations/, встроенными в бинарник: NNNN_имя.up.sql
+// вносит изменение, NNNN_имя.down.sql откатывает его. Применённые версии записываются в
+// schema_migrations. При запуске сервис применяет недостающие миграции, а подкоманда
+// migrate позволяет управлять ими вручную:
+//
+//	file-storing migrate up [версия]  — применить миграции (до версии включительно)
+//	file-storing migrate down [N]     — откатить N последних миграций (по умолчанию одну)
+//	file-storing migrate status       — какие миграции применены
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// legacyColumns — колонки, которые версии сервиса до миграций добавляли в существующие
+// таблицы при запуске. В БД такой версии их может не хватать, а миграция 0001 таблицу,
+// которая уже есть, не трогает.
+var legacyColumns = []migrate.Column{
+	{Table: "files", Name: "encoding", Definition: "TEXT NOT NULL DEFAULT 'utf-8'"},
+	{Table: "files", Name: "text_path", Definition: "TEXT"},
+	{Table: "submission_files", Name: "encoding", Definition: "TEXT NOT NULL DEFAULT 'utf-8'"},
+	{Table: "files", Name: "content_check", Definition: "TEXT"},
+	{Table: "files", Name: "content_reason", Definition: "TEXT"},
+	{Table: "files", Name: "sha256", Definition: "TEXT"},
+	{Table: "files", Name: "size", Definition: "INTEGER"},
+	{Table: "files", Name: "normalized_sha256", Definition: "TEXT"},
+	{Table: "files", Name: "original_filename", Definition: "TEXT"},
+	{Table: "files", Name: "mime", Definition: "TEXT"},
+	{Table: "files", Name: "language", Definition: "TEXT"},
+	{Table: "files", Name: "line_count", Definition: "INTEGER"},
+	{Table: "files", Name: "comment", Definition: "TEXT"},
+	{Table: "files", Name: "queued_at", Definition: "DATETIME"},
+	{Table: "files", Name: "analysis_started_at", Definition: "DATETIME"},
+	{Table: "files", Name: "finished_at", Definition: "DATETIME"},
+	{Table: "files", Name: "late", Definition: "INTEGER NOT NULL DEFAULT 0"},
+	{Table: "files", Name: "version", Definition: "INTEGER"},
+	{Table: "files", Name: "is_final", Definition: "INTEGER NOT NULL DEFAULT 0"},
+}
+
+func migrator() *migrate.Migrator {
+	files, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		panic("Ошибка чтения миграций: " + err.Error())
+	}
+	return &migrate.Migrator{DB: db, Files: files, Legacy: legacyColumns}
+}
+
+// migrateUp применяет ещё не применённые миграции до версии target (0 — все).
+func migrateUp(target int) (int, error) {
+	return migrator().Up(target)
+}
+
+// runMigrate — подкоманда migrate up|down|status.
+func runMigrate(args []string) {
+	migrator().Run(args)
+}
@@ -0,0 +1,9 @@
+-- Удаляет все данные сервиса: откатывать только на пустой или ненужной БД.
+DROP TABLE IF EXISTS enrolments;
+DROP TABLE IF EXISTS students;
+DROP TABLE IF EXISTS student_groups;
+DROP TABLE IF EXISTS courses;
+DROP TABLE IF EXISTS assignments;
+DROP TABLE IF EXISTS tus_uploads;
+DROP TABLE IF EXISTS submission_files;
+DROP TABLE IF EXISTS files;
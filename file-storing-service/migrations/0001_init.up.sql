@@ -0,0 +1,83 @@
+-- Схема, до которой сервис дорос до появления миграций. IF NOT EXISTS — потому что в БД,
+-- созданных старыми версиями сервиса, часть этих таблиц уже есть.
+CREATE TABLE IF NOT EXISTS files (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	student_id TEXT NOT NULL,
+	assignment_id TEXT NOT NULL,
+	file_path TEXT NOT NULL,
+	uploaded_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	status TEXT DEFAULT 'uploaded',
+	encoding TEXT NOT NULL DEFAULT 'utf-8',
+	text_path TEXT,
+	content_check TEXT,
+	content_reason TEXT,
+	sha256 TEXT,
+	size INTEGER,
+	normalized_sha256 TEXT,
+	original_filename TEXT,
+	mime TEXT,
+	language TEXT,
+	line_count INTEGER,
+	comment TEXT,
+	queued_at DATETIME,
+	analysis_started_at DATETIME,
+	finished_at DATETIME,
+	late INTEGER NOT NULL DEFAULT 0,
+	version INTEGER,
+	is_final INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS submission_files (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	file_id INTEGER NOT NULL,
+	member_path TEXT NOT NULL,
+	file_path TEXT NOT NULL,
+	encoding TEXT NOT NULL DEFAULT 'utf-8'
+);
+
+CREATE TABLE IF NOT EXISTS tus_uploads (
+	id TEXT PRIMARY KEY,
+	upload_length INTEGER NOT NULL,
+	filename TEXT NOT NULL,
+	student_id TEXT NOT NULL,
+	assignment_id TEXT NOT NULL,
+	comment TEXT NOT NULL DEFAULT '',
+	file_id INTEGER,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS assignments (
+	id TEXT PRIMARY KEY,
+	title TEXT NOT NULL,
+	course TEXT NOT NULL DEFAULT '',
+	language TEXT NOT NULL DEFAULT '',
+	opens_at DATETIME,
+	closes_at DATETIME,
+	allow_late INTEGER NOT NULL DEFAULT 0,
+	allowed_formats TEXT NOT NULL DEFAULT '',
+	max_size INTEGER NOT NULL DEFAULT 0,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS courses (
+	id TEXT PRIMARY KEY,
+	title TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS student_groups (
+	id TEXT PRIMARY KEY,
+	title TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS students (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL DEFAULT '',
+	group_id TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS enrolments (
+	course_id TEXT NOT NULL,
+	student_id TEXT NOT NULL,
+	PRIMARY KEY (course_id, student_id)
+);
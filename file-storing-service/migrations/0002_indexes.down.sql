@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_enrolments_student_id;
+DROP INDEX IF EXISTS idx_students_group_id;
+DROP INDEX IF EXISTS idx_submission_files_file_id;
+DROP INDEX IF EXISTS idx_files_student_id_assignment_id;
+DROP INDEX IF EXISTS idx_files_assignment_id;
@@ -0,0 +1,7 @@
+-- Работы ищутся по заданию (сравнение, копии, квоты) и по студенту и заданию (версии сдачи,
+-- квоты); индекс по (student_id, assignment_id) служит и для поиска по одному student_id.
+CREATE INDEX idx_files_assignment_id ON files (assignment_id);
+CREATE INDEX idx_files_student_id_assignment_id ON files (student_id, assignment_id);
+CREATE INDEX idx_submission_files_file_id ON submission_files (file_id);
+CREATE INDEX idx_students_group_id ON students (group_id);
+CREATE INDEX idx_enrolments_student_id ON enrolments (student_id);
@@ -0,0 +1 @@
+ALTER TABLE files DROP COLUMN status_updated_at;
@@ -0,0 +1,4 @@
+-- Время последней смены статуса: работу, которая зависла в очереди или анализе дольше
+-- STATUS_STALE_AFTER, можно снова поставить в очередь.
+ALTER TABLE files ADD COLUMN status_updated_at DATETIME;
+UPDATE files SET status_updated_at = COALESCE(finished_at, analysis_started_at, queued_at, uploaded_at);
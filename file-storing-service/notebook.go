@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"shared/formats"
+	"shared/notebook"
+)
+
+// notebookPreview показывает блокнот по ячейкам: код — с подсветкой языка блокнота,
+// markdown — как текст. Если JSON не разбирается, блокнот показывается как есть.
+func notebookPreview(name string, content []byte, format *formats.Format) []previewFile {
+	cells, err := notebook.Parse(content)
+	if err != nil {
+		return []previewFile{{Name: name + " (не разобран как блокнот)", Lines: highlight(string(content), nil)}}
+	}
+	var files []previewFile
+	for i, cell := range cells {
+		source := cell.Source
+		if strings.TrimSpace(source) == "" {
+			continue
+		}
+		switch cell.Type {
+		case "code":
+			files = append(files, previewFile{Name: fmt.Sprintf("%s — ячейка %d, код", name, i+1), Lines: highlight(source, format)})
+		case "markdown":
+			files = append(files, previewFile{Name: fmt.Sprintf("%s — ячейка %d, markdown", name, i+1), Lines: highlight(source, nil)})
+		}
+	}
+	if len(files) == 0 {
+		files = append(files, previewFile{Name: name, Note: "В блокноте нет ячеек с кодом или текстом"})
+	}
+	return files
+}
+
+// previewFor готовит предпросмотр одного файла работы.
+func previewFor(name string, content []byte) []previewFile {
+	format := formats.For(name)
+	if format != nil && format.Tokenizer == "notebook" {
+		return notebookPreview(name, content, format)
+	}
+	return []previewFile{{Name: name, Lines: highlight(string(content), format)}}
+}
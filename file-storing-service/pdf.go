@@ -0,0 +1,875 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Извлечение текста из PDF без OCR: разбираются объекты файла (включая потоки объектов),
+// страницы в порядке дерева /Pages и текстовые операторы их содержимого. Коды символов
+// переводятся в Unicode через ToUnicode-таблицы шрифтов, а без таблицы — как Latin-1.
+
+// errNoText — PDF корректный, но текста в нём нет (скан или изображения).
+var errNoText = errors.New("в PDF нет извлекаемого текста")
+
+type pdfName string
+type pdfKeyword string
+type pdfString []byte
+
+type pdfRef struct {
+	num int
+	gen int
+}
+
+type pdfDict map[pdfName]interface{}
+
+type pdfStream struct {
+	dict pdfDict
+	data []byte
+}
+
+type pdfFont struct {
+	toUnicode map[string]string
+	codeLen   int
+}
+
+type pdfDocument struct {
+	objects map[int]interface{}
+	fonts   map[pdfRef]*pdfFont
+	// root — /Root из трейлера (или из словаря потока перекрёстных ссылок).
+	root interface{}
+	// decoded и cmapEntries — сколько всего распаковано байт потоков и записей ToUnicode
+	// в документе: одни и те же сжатые потоки и таблицы могут подключаться многократно.
+	decoded     int64
+	cmapEntries int
+	// err — документ превысил лимиты, извлечённый текст неполный.
+	err error
+}
+
+var pdfObjectHeader = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+// pdfMaxDepth — предел вложенности массивов и словарей, а также дерева страниц. Разбор
+// рекурсивный, и без предела файл из одних «[» переполняет стек: это фатальная ошибка,
+// которая роняет весь сервис.
+const pdfMaxDepth = 64
+
+var errPDFTooDeep = fmt.Errorf("вложенность объектов PDF больше %d", pdfMaxDepth)
+
+const (
+	// pdfMaxPages — предел числа страниц в дереве /Pages.
+	pdfMaxPages = 10000
+	// pdfMaxCMapEntries — предел записей всех таблиц ToUnicode документа.
+	pdfMaxCMapEntries = 1 << 20
+)
+
+var pdfTrailer = regexp.MustCompile(`trailer\s*<<`)
+
+func newPDFDocument() *pdfDocument {
+	return &pdfDocument{objects: map[int]interface{}{}, fonts: map[pdfRef]*pdfFont{}}
+}
+
+// extractPDF извлекает текст из PDF-документа.
+func extractPDF(r io.ReaderAt, size int64) (string, error) {
+	if size > limits.MaxTotalSize {
+		return "", fmt.Errorf("%w: PDF больше %d байт", errArchiveLimit, limits.MaxTotalSize)
+	}
+	data := make([]byte, size)
+	if _, err := r.ReadAt(data, 0); err != nil && err != io.EOF {
+		return "", err
+	}
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \r\n\t"), []byte("%PDF-")) {
+		return "", errors.New("файл не является PDF")
+	}
+	if bytes.Contains(data, []byte("/Encrypt")) {
+		return "", errors.New("PDF зашифрован")
+	}
+	doc := newPDFDocument()
+	doc.parseObjects(data)
+	doc.parseTrailer(data)
+	doc.parseObjectStreams()
+
+	// Распакованные потоки и извлечённый текст вместе ограничены тем же лимитом, что и
+	// распакованный архив
+	var sb strings.Builder
+	for _, page := range doc.pages() {
+		doc.pageText(page, &sb)
+		sb.WriteString("\n")
+		if doc.err != nil {
+			return "", doc.err
+		}
+	}
+	text := sb.String()
+	if strings.TrimSpace(text) == "" {
+		return "", errNoText
+	}
+	return text, nil
+}
+
+// parseObjects ищет заголовки «N G obj» и разбирает объекты. Данные потоков пропускаются:
+// в сжатом или бинарном содержимом могут встретиться байты, похожие на заголовок объекта.
+func (d *pdfDocument) parseObjects(data []byte) {
+	pos := 0
+	for {
+		m := pdfObjectHeader.FindSubmatchIndex(data[pos:])
+		if m == nil {
+			return
+		}
+		num, _ := strconv.Atoi(string(data[pos+m[2] : pos+m[3]]))
+		l := &pdfLexer{data: data, pos: pos + m[1]}
+		pos += m[1]
+		value, err := l.parseValue()
+		if err != nil {
+			continue
+		}
+		if dict, ok := value.(pdfDict); ok {
+			if stream, ok := l.readStream(dict); ok {
+				value = stream
+				pos = l.pos
+			}
+			// В PDF 1.5+ трейлер хранится в словаре потока перекрёстных ссылок; последний
+			// в файле относится к последней редакции документа
+			if dict["Type"] == pdfName("XRef") && dict["Root"] != nil {
+				d.root = dict["Root"]
+			}
+		}
+		d.objects[num] = value
+	}
+}
+
+// parseTrailer берёт /Root из последнего трейлера файла.
+func (d *pdfDocument) parseTrailer(data []byte) {
+	matches := pdfTrailer.FindAllIndex(data, -1)
+	for i := len(matches) - 1; i >= 0; i-- {
+		l := &pdfLexer{data: data, pos: matches[i][0] + len("trailer")}
+		if dict, ok := parsedDict(l.parseValue()); ok && dict["Root"] != nil {
+			d.root = dict["Root"]
+			return
+		}
+	}
+}
+
+func parsedDict(value interface{}, err error) (pdfDict, bool) {
+	dict, ok := value.(pdfDict)
+	return dict, ok && err == nil
+}
+
+// parseObjectStreams достаёт объекты из потоков /ObjStm (PDF 1.5+), в которых
+// современные редакторы хранят словари шрифтов и страниц.
+func (d *pdfDocument) parseObjectStreams() {
+	for _, obj := range d.objects {
+		stream, ok := obj.(pdfStream)
+		if !ok || stream.dict["Type"] != pdfName("ObjStm") {
+			continue
+		}
+		data, err := d.decode(stream)
+		if err != nil {
+			continue
+		}
+		n, _ := d.resolve(stream.dict["N"]).(int)
+		first, _ := d.resolve(stream.dict["First"]).(int)
+		header := &pdfLexer{data: data}
+		for i := 0; i < n; i++ {
+			numTok, err1 := header.next()
+			offTok, err2 := header.next()
+			num, ok1 := numTok.(int)
+			off, ok2 := offTok.(int)
+			if err1 != nil || err2 != nil || !ok1 || !ok2 || first+off >= len(data) {
+				break
+			}
+			if _, exists := d.objects[num]; exists {
+				continue
+			}
+			value, err := (&pdfLexer{data: data, pos: first + off}).parseValue()
+			if err == nil {
+				d.objects[num] = value
+			}
+		}
+	}
+}
+
+func (d *pdfDocument) resolve(v interface{}) interface{} {
+	for i := 0; i < 32; i++ {
+		ref, ok := v.(pdfRef)
+		if !ok {
+			return v
+		}
+		v = d.objects[ref.num]
+	}
+	return nil
+}
+
+func (d *pdfDocument) dict(v interface{}) pdfDict {
+	switch t := d.resolve(v).(type) {
+	case pdfDict:
+		return t
+	case pdfStream:
+		return t.dict
+	}
+	return nil
+}
+
+type pdfPage struct {
+	dict      pdfDict
+	resources pdfDict
+}
+
+// catalog возвращает каталог документа: из /Root трейлера, а если трейлер не найден или
+// повреждён — каталог с наименьшим номером объекта.
+func (d *pdfDocument) catalog() pdfDict {
+	if root := d.dict(d.root); root != nil && root["Type"] == pdfName("Catalog") {
+		return root
+	}
+	var catalog pdfDict
+	first := -1
+	for num, obj := range d.objects {
+		if dict, ok := obj.(pdfDict); ok && dict["Type"] == pdfName("Catalog") && (first == -1 || num < first) {
+			catalog, first = dict, num
+		}
+	}
+	return catalog
+}
+
+// pages возвращает страницы в порядке документа, обходя дерево /Pages от каталога. Каждый
+// узел посещается один раз: иначе /Kids, который ссылается на один узел дважды или на
+// предка, даёт экспоненциальный или бесконечный обход.
+func (d *pdfDocument) pages() []pdfPage {
+	var pages []pdfPage
+	visited := map[pdfRef]bool{}
+	var walk func(node interface{}, resources pdfDict, depth int)
+	walk = func(node interface{}, resources pdfDict, depth int) {
+		if depth > pdfMaxDepth || len(pages) >= pdfMaxPages {
+			return
+		}
+		if ref, ok := node.(pdfRef); ok {
+			if visited[ref] {
+				return
+			}
+			visited[ref] = true
+		}
+		dict := d.dict(node)
+		if dict == nil {
+			return
+		}
+		if res := d.dict(dict["Resources"]); res != nil {
+			resources = res
+		}
+		if dict["Type"] == pdfName("Page") {
+			pages = append(pages, pdfPage{dict: dict, resources: resources})
+			return
+		}
+		kids, _ := d.resolve(dict["Kids"]).([]interface{})
+		for _, kid := range kids {
+			walk(kid, resources, depth+1)
+		}
+	}
+	if catalog := d.catalog(); catalog != nil {
+		walk(catalog["Pages"], nil, 0)
+	}
+	return pages
+}
+
+func (d *pdfDocument) pageText(page pdfPage, sb *strings.Builder) {
+	var contents []interface{}
+	switch c := d.resolve(page.dict["Contents"]).(type) {
+	case pdfStream:
+		contents = []interface{}{c}
+	case []interface{}:
+		contents = c
+	}
+	var data []byte
+	for _, c := range contents {
+		stream, ok := d.resolve(c).(pdfStream)
+		if !ok {
+			continue
+		}
+		decoded, err := d.decode(stream)
+		if err != nil {
+			continue
+		}
+		data = append(data, decoded...)
+		data = append(data, '\n')
+	}
+	fonts := d.dict(page.resources["Font"])
+	d.contentText(data, fonts, sb)
+}
+
+// contentText выполняет текстовые операторы содержимого страницы.
+func (d *pdfDocument) contentText(data []byte, fonts pdfDict, sb *strings.Builder) {
+	l := &pdfLexer{data: data}
+	var operands []interface{}
+	var font *pdfFont
+	for {
+		if int64(sb.Len()) > limits.MaxTotalSize {
+			d.err = fmt.Errorf("%w: текст PDF больше %d байт", errArchiveLimit, limits.MaxTotalSize)
+			return
+		}
+		value, err := l.parseValue()
+		if err != nil {
+			return
+		}
+		op, ok := value.(pdfKeyword)
+		if !ok || op == "true" || op == "false" || op == "null" {
+			operands = append(operands, value)
+			continue
+		}
+		switch op {
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[len(operands)-2].(pdfName); ok {
+					font = d.font(fonts[name])
+				}
+			}
+		case "Tj":
+			if len(operands) >= 1 {
+				sb.WriteString(font.decode(operands[len(operands)-1]))
+			}
+		case "'", "\"":
+			sb.WriteString("\n")
+			if len(operands) >= 1 {
+				sb.WriteString(font.decode(operands[len(operands)-1]))
+			}
+		case "TJ":
+			if len(operands) >= 1 {
+				items, _ := operands[len(operands)-1].([]interface{})
+				for _, item := range items {
+					if offset, ok := number(item); ok {
+						if offset < -250 {
+							sb.WriteString(" ")
+						}
+						continue
+					}
+					sb.WriteString(font.decode(item))
+				}
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				if ty, ok := number(operands[len(operands)-1]); ok && ty != 0 {
+					sb.WriteString("\n")
+				} else {
+					sb.WriteString(" ")
+				}
+			}
+		case "T*", "Tm", "ET":
+			sb.WriteString("\n")
+		case "ID":
+			l.skipInlineImage()
+		}
+		operands = operands[:0]
+	}
+}
+
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func (d *pdfDocument) font(v interface{}) *pdfFont {
+	ref, isRef := v.(pdfRef)
+	if isRef {
+		if f, ok := d.fonts[ref]; ok {
+			return f
+		}
+	}
+	f := &pdfFont{codeLen: 1}
+	if dict := d.dict(v); dict != nil {
+		if stream, ok := d.resolve(dict["ToUnicode"]).(pdfStream); ok {
+			if data, err := d.decode(stream); err == nil {
+				f.toUnicode, f.codeLen = parseToUnicode(data, &d.cmapEntries)
+			}
+		}
+		if f.toUnicode == nil && dict["Subtype"] == pdfName("Type0") {
+			// Составной шрифт без ToUnicode: коды символов не связаны с Unicode
+			f.codeLen = 0
+		}
+	}
+	if isRef {
+		d.fonts[ref] = f
+	}
+	return f
+}
+
+// decode переводит строку из кодов шрифта в Unicode.
+func (f *pdfFont) decode(v interface{}) string {
+	s, ok := v.(pdfString)
+	if !ok {
+		return ""
+	}
+	if f == nil || f.toUnicode == nil {
+		if f != nil && f.codeLen == 0 {
+			return ""
+		}
+		runes := make([]rune, len(s))
+		for i, b := range s {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	}
+	var sb strings.Builder
+	for i := 0; i+f.codeLen <= len(s); i += f.codeLen {
+		if u, ok := f.toUnicode[string(s[i:i+f.codeLen])]; ok {
+			sb.WriteString(u)
+		}
+	}
+	return sb.String()
+}
+
+// parseToUnicode разбирает CMap с секциями bfchar и bfrange. entries — сколько записей уже
+// разобрано в документе; после pdfMaxCMapEntries новые записи не добавляются.
+func parseToUnicode(data []byte, entries *int) (map[string]string, int) {
+	mapping := map[string]string{}
+	codeLen := 0
+	l := &pdfLexer{data: data}
+	var operands []interface{}
+	mode := ""
+	for *entries < pdfMaxCMapEntries {
+		value, err := l.parseValue()
+		if err != nil {
+			break
+		}
+		switch v := value.(type) {
+		case pdfKeyword:
+			switch v {
+			case "beginbfchar", "beginbfrange", "begincodespacerange":
+				mode = string(v)
+			case "endbfchar", "endbfrange", "endcodespacerange":
+				mode = ""
+			}
+			operands = operands[:0]
+			continue
+		default:
+			operands = append(operands, value)
+		}
+		switch mode {
+		case "begincodespacerange":
+			if len(operands) == 2 {
+				if lo, ok := operands[0].(pdfString); ok && codeLen == 0 {
+					codeLen = len(lo)
+				}
+				operands = operands[:0]
+			}
+		case "beginbfchar":
+			if len(operands) == 2 {
+				src, ok1 := operands[0].(pdfString)
+				dst, ok2 := operands[1].(pdfString)
+				if ok1 && ok2 {
+					mapping[string(src)] = utf16String(dst)
+					*entries++
+					if codeLen == 0 {
+						codeLen = len(src)
+					}
+				}
+				operands = operands[:0]
+			}
+		case "beginbfrange":
+			if len(operands) == 3 {
+				addBfRange(mapping, operands, entries)
+				if lo, ok := operands[0].(pdfString); ok && codeLen == 0 {
+					codeLen = len(lo)
+				}
+				operands = operands[:0]
+			}
+		default:
+			operands = operands[:0]
+		}
+	}
+	if codeLen == 0 {
+		codeLen = 1
+	}
+	return mapping, codeLen
+}
+
+func addBfRange(mapping map[string]string, operands []interface{}, entries *int) {
+	lo, ok1 := operands[0].(pdfString)
+	hi, ok2 := operands[1].(pdfString)
+	if !ok1 || !ok2 || len(lo) != len(hi) || len(lo) == 0 || len(lo) > 4 {
+		return
+	}
+	start, end := codeValue(lo), codeValue(hi)
+	if end < start || end-start > 0xFFFF {
+		return
+	}
+	for code := start; code <= end && *entries < pdfMaxCMapEntries; code++ {
+		*entries++
+		src := make([]byte, len(lo))
+		for i := range src {
+			src[i] = byte(code >> (8 * (len(lo) - 1 - i)))
+		}
+		switch dst := operands[2].(type) {
+		case pdfString:
+			if len(dst) == 0 {
+				return
+			}
+			next := append(pdfString{}, dst...)
+			next[len(next)-1] += byte(code - start)
+			mapping[string(src)] = utf16String(next)
+		case []interface{}:
+			if idx := int(code - start); idx < len(dst) {
+				if s, ok := dst[idx].(pdfString); ok {
+					mapping[string(src)] = utf16String(s)
+				}
+			}
+		}
+	}
+}
+
+func codeValue(b []byte) uint32 {
+	var v uint32
+	for _, c := range b {
+		v = v<<8 | uint32(c)
+	}
+	return v
+}
+
+func utf16String(b []byte) string {
+	units := make([]uint16, 0, len(b)/2)
+	for i := 0; i+1 < len(b); i += 2 {
+		units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+	}
+	if len(b) == 1 {
+		return string(rune(b[0]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// decode распаковывает поток в пределах лимита на все распакованные потоки документа.
+func (d *pdfDocument) decode(s pdfStream) ([]byte, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	data, err := decodeStream(s, limits.MaxTotalSize-d.decoded)
+	// Несжатый поток не проходит через лимит распаковки, но тоже считается
+	if errors.Is(err, errArchiveLimit) || d.decoded+int64(len(data)) > limits.MaxTotalSize {
+		d.err = fmt.Errorf("%w: распакованные потоки PDF больше %d байт", errArchiveLimit, limits.MaxTotalSize)
+		return nil, d.err
+	}
+	d.decoded += int64(len(data))
+	return data, err
+}
+
+// decodeStream распаковывает поток. Поддерживается FlateDecode — его используют почти все
+// генераторы PDF для текста; размер распакованных данных ограничен max, чтобы не пропустить бомбу.
+func decodeStream(s pdfStream, max int64) ([]byte, error) {
+	var filters []interface{}
+	switch f := s.dict["Filter"].(type) {
+	case pdfName:
+		filters = []interface{}{f}
+	case []interface{}:
+		filters = f
+	}
+	data := s.data
+	for _, f := range filters {
+		if f != pdfName("FlateDecode") {
+			return nil, fmt.Errorf("фильтр %v не поддерживается", f)
+		}
+		zr, err := zlib.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		decoded, err := io.ReadAll(io.LimitReader(zr, max+1))
+		zr.Close()
+		if int64(len(decoded)) > max {
+			return nil, errArchiveLimit
+		}
+		// Многие генераторы обрезают контрольную сумму zlib — используем то, что прочитали
+		if err != nil && len(decoded) == 0 {
+			return nil, err
+		}
+		data = decoded
+	}
+	return data, nil
+}
+
+// pdfLexer разбирает синтаксис PDF: числа, имена, строки, массивы, словари, ссылки и операторы.
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+var errPDFEnd = errors.New("конец данных PDF")
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) != -1
+}
+
+func (l *pdfLexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if isPDFSpace(c) {
+			l.pos++
+		} else if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		} else {
+			return
+		}
+	}
+}
+
+func (l *pdfLexer) next() (interface{}, error) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, errPDFEnd
+	}
+	c := l.data[l.pos]
+	switch {
+	case c == '(':
+		return l.literalString(), nil
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		return pdfKeyword("<<"), nil
+	case c == '>' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '>':
+		l.pos += 2
+		return pdfKeyword(">>"), nil
+	case c == '<':
+		return l.hexString(), nil
+	case c == '[' || c == ']' || c == '{' || c == '}':
+		l.pos++
+		return pdfKeyword(string(c)), nil
+	case c == '/':
+		l.pos++
+		start := l.pos
+		for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+			l.pos++
+		}
+		return pdfName(decodeName(l.data[start:l.pos])), nil
+	}
+	start := l.pos
+	for l.pos < len(l.data) && !isPDFSpace(l.data[l.pos]) && !isPDFDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	if l.pos == start {
+		l.pos++
+		return pdfKeyword(string(c)), nil
+	}
+	word := string(l.data[start:l.pos])
+	if n, err := strconv.Atoi(word); err == nil {
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(word, 64); err == nil {
+		return f, nil
+	}
+	return pdfKeyword(word), nil
+}
+
+// parseValue читает значение целиком, собирая массивы, словари и ссылки вида «12 0 R».
+func (l *pdfLexer) parseValue() (interface{}, error) {
+	return l.parseNested(0)
+}
+
+// parseNested читает значение на глубине depth; глубже pdfMaxDepth разбор прекращается.
+func (l *pdfLexer) parseNested(depth int) (interface{}, error) {
+	tok, err := l.next()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case pdfKeyword:
+		if (t == "[" || t == "<<") && depth >= pdfMaxDepth {
+			return nil, errPDFTooDeep
+		}
+		switch t {
+		case "[":
+			var arr []interface{}
+			for {
+				item, err := l.parseNested(depth + 1)
+				if err != nil {
+					return arr, err
+				}
+				if item == pdfKeyword("]") {
+					return arr, nil
+				}
+				arr = append(arr, item)
+			}
+		case "<<":
+			dict := pdfDict{}
+			for {
+				key, err := l.parseNested(depth + 1)
+				if err != nil {
+					return dict, err
+				}
+				if key == pdfKeyword(">>") {
+					return dict, nil
+				}
+				name, ok := key.(pdfName)
+				if !ok {
+					continue
+				}
+				value, err := l.parseNested(depth + 1)
+				if err != nil {
+					return dict, err
+				}
+				dict[name] = value
+			}
+		}
+	case int:
+		saved := l.pos
+		gen, err1 := l.next()
+		r, err2 := l.next()
+		if g, ok := gen.(int); ok && err1 == nil && err2 == nil && r == pdfKeyword("R") {
+			return pdfRef{num: t, gen: g}, nil
+		}
+		l.pos = saved
+	}
+	return tok, nil
+}
+
+func (l *pdfLexer) readStream(dict pdfDict) (pdfStream, bool) {
+	saved := l.pos
+	tok, err := l.next()
+	if err != nil || tok != pdfKeyword("stream") {
+		l.pos = saved
+		return pdfStream{}, false
+	}
+	if l.pos < len(l.data) && l.data[l.pos] == '\r' {
+		l.pos++
+	}
+	if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+		l.pos++
+	}
+	start := l.pos
+	end := -1
+	if length, ok := dict["Length"].(int); ok && length >= 0 && start+length <= len(l.data) {
+		if bytes.HasPrefix(bytes.TrimLeft(l.data[start+length:], " \r\n"), []byte("endstream")) {
+			end = start + length
+		}
+	}
+	if end == -1 {
+		idx := bytes.Index(l.data[start:], []byte("endstream"))
+		if idx == -1 {
+			return pdfStream{}, false
+		}
+		end = start + idx
+		for end > start && (l.data[end-1] == '\n' || l.data[end-1] == '\r') {
+			end--
+		}
+	}
+	l.pos = end
+	return pdfStream{dict: dict, data: l.data[start:end]}, true
+}
+
+func (l *pdfLexer) literalString() pdfString {
+	l.pos++
+	var out []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return out
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return out
+			}
+			e := l.data[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				if l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+						v = v*8 + int(l.data[l.pos]-'0')
+						l.pos++
+					}
+					c = byte(v)
+				} else {
+					c = e
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+func (l *pdfLexer) hexString() pdfString {
+	l.pos++
+	var digits []byte
+	for l.pos < len(l.data) && l.data[l.pos] != '>' {
+		c := l.data[l.pos]
+		if (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		v, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		out[i] = byte(v)
+	}
+	return out
+}
+
+// skipInlineImage пропускает двоичные данные встроенного изображения между ID и EI.
+func (l *pdfLexer) skipInlineImage() {
+	for l.pos+2 < len(l.data) {
+		if isPDFSpace(l.data[l.pos]) && l.data[l.pos+1] == 'E' && l.data[l.pos+2] == 'I' &&
+			(l.pos+3 == len(l.data) || isPDFSpace(l.data[l.pos+3])) {
+			l.pos += 3
+			return
+		}
+		l.pos++
+	}
+	l.pos = len(l.data)
+}
+
+func decodeName(b []byte) string {
+	if bytes.IndexByte(b, '#') == -1 {
+		return string(b)
+	}
+	var out []byte
+	for i := 0; i < len(b); i++ {
+		if b[i] == '#' && i+2 < len(b) {
+			if v, err := strconv.ParseUint(string(b[i+1:i+3]), 16, 8); err == nil {
+				out = append(out, byte(v))
+				i += 2
+				continue
+			}
+		}
+		out = append(out, b[i])
+	}
+	return string(out)
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testPDF собирает PDF из объектов с номерами 1, 2, … и трейлера.
+func testPDF(trailer string, objects ...string) []byte {
+	var b strings.Builder
+	b.WriteString("%PDF-1.4\n")
+	for i, obj := range objects {
+		b.WriteString(strconv.Itoa(i+1) + " 0 obj\n" + obj + "\nendobj\n")
+	}
+	if trailer != "" {
+		b.WriteString("trailer\n" + trailer + "\n")
+	}
+	b.WriteString("%%EOF\n")
+	return []byte(b.String())
+}
+
+func testStream(content string) string {
+	return "<< /Length " + strconv.Itoa(len(content)) + " >>\nstream\n" + content + "\nendstream"
+}
+
+func TestExtractPDFDeepNesting(t *testing.T) {
+	// 10 МБ «[» после заголовка объекта раньше переполняли стек и роняли сервис.
+	data := append([]byte("%PDF-1.4\n1 0 obj\n"), bytes.Repeat([]byte("["), 10<<20)...)
+	if _, err := extractPDF(bytes.NewReader(data), int64(len(data))); err == nil {
+		t.Fatal("extractPDF вернул текст для PDF без страниц")
+	}
+	for _, open := range []string{"[", "<< /A "} {
+		l := &pdfLexer{data: []byte(strings.Repeat(open, pdfMaxDepth+1))}
+		if _, err := l.parseValue(); !errors.Is(err, errPDFTooDeep) {
+			t.Fatalf("parseValue(%q × %d): ошибка %v, ожидалась errPDFTooDeep", open, pdfMaxDepth+1, err)
+		}
+	}
+}
+
+func TestParseValueAtDepthLimit(t *testing.T) {
+	src := strings.Repeat("[", pdfMaxDepth) + "1" + strings.Repeat("]", pdfMaxDepth)
+	value, err := (&pdfLexer{data: []byte(src)}).parseValue()
+	if err != nil {
+		t.Fatalf("parseValue на глубине %d: %v", pdfMaxDepth, err)
+	}
+	for depth := 1; depth < pdfMaxDepth; depth++ {
+		arr, ok := value.([]interface{})
+		if !ok || len(arr) != 1 {
+			t.Fatalf("глубина %d: %#v", depth, value)
+		}
+		value = arr[0]
+	}
+	if arr, ok := value.([]interface{}); !ok || len(arr) != 1 || arr[0] != 1 {
+		t.Fatalf("самый вложенный массив: %#v", value)
+	}
+}
+
+func TestParseObjectsSkipsStreamData(t *testing.T) {
+	data := []byte("%PDF-1.4\n" +
+		"1 0 obj\n<< /Length 22 >>\nstream\n9 0 obj (fake) endobj\nendstream\nendobj\n" +
+		"2 0 obj\n(real)\nendobj\n")
+	doc := newPDFDocument()
+	doc.parseObjects(data)
+	if _, ok := doc.objects[9]; ok {
+		t.Fatal("заголовок объекта внутри данных потока разобран как объект")
+	}
+	stream, ok := doc.objects[1].(pdfStream)
+	if !ok || string(stream.data) != "9 0 obj (fake) endobj\n" {
+		t.Fatalf("объект 1: %#v", doc.objects[1])
+	}
+	if s, ok := doc.objects[2].(pdfString); !ok || string(s) != "real" {
+		t.Fatalf("объект после потока: %#v", doc.objects[2])
+	}
+}
+
+func TestExtractPDFText(t *testing.T) {
+	content := "BT /F1 12 Tf (Hello PDF) Tj ET"
+	data := []byte("%PDF-1.4\n" +
+		"1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n" +
+		"2 0 obj\n<< /Type /Pages /Kids [3 0 R] /Count 1 >>\nendobj\n" +
+		"3 0 obj\n<< /Type /Page /Parent 2 0 R /Resources << /Font << /F1 5 0 R >> >> /Contents 4 0 R >>\nendobj\n" +
+		"4 0 obj\n<< /Length " + strconv.Itoa(len(content)) + " >>\nstream\n" + content + "\nendstream\nendobj\n" +
+		"5 0 obj\n<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>\nendobj\n" +
+		"%%EOF\n")
+	text, err := extractPDF(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text, "Hello PDF") {
+		t.Fatalf("извлечён текст %q", text)
+	}
+}
+
+func TestExtractPDFPageTreeLoops(t *testing.T) {
+	cases := map[string][]byte{
+		// Каждый уровень удваивает число путей: без учёта посещённых узлов — 2^64 вызовов
+		"повтор в /Kids": testPDF("<< /Root 1 0 R >>",
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Type /Pages /Kids [2 0 R 2 0 R] >>"),
+		"цикл через потомка": testPDF("<< /Root 1 0 R >>",
+			"<< /Type /Catalog /Pages 2 0 R >>",
+			"<< /Type /Pages /Kids [3 0 R 3 0 R] >>",
+			"<< /Type /Pages /Kids [2 0 R 3 0 R 2 0 R] >>"),
+	}
+	for name, data := range cases {
+		start := time.Now()
+		_, err := extractPDF(bytes.NewReader(data), int64(len(data)))
+		if !errors.Is(err, errNoText) {
+			t.Errorf("%s: ошибка %v, ожидалась errNoText", name, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s: разбор занял %v", name, elapsed)
+		}
+	}
+}
+
+func TestExtractPDFSharedPageVisitedOnce(t *testing.T) {
+	data := testPDF("<< /Root 1 0 R >>",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R 3 0 R] >>",
+		"<< /Type /Page /Contents 4 0 R >>",
+		testStream("BT (once) Tj ET"))
+	text, err := extractPDF(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(text, "once"); n != 1 {
+		t.Fatalf("страница извлечена %d раз: %q", n, text)
+	}
+}
+
+func TestExtractPDFCatalogFromTrailer(t *testing.T) {
+	// Два каталога: документ выбирается по /Root трейлера, а не по порядку обхода объектов
+	data := testPDF("<< /Root 5 0 R >>",
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] >>",
+		"<< /Type /Page /Contents 4 0 R >>",
+		testStream("BT (old) Tj ET"),
+		"<< /Type /Catalog /Pages 6 0 R >>",
+		"<< /Type /Pages /Kids [7 0 R] >>",
+		"<< /Type /Page /Contents 8 0 R >>",
+		testStream("BT (new) Tj ET"))
+	for i := 0; i < 20; i++ {
+		text, err := extractPDF(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSpace(text) != "new" {
+			t.Fatalf("извлечён текст %q, ожидался текст каталога из трейлера", text)
+		}
+	}
+}
+
+func TestExtractPDFSharedCompressedStream(t *testing.T) {
+	saved := limits.MaxTotalSize
+	limits.MaxTotalSize = 4 << 20
+	defer func() { limits.MaxTotalSize = saved }()
+
+	// Поток в 1 МБ сжимается в несколько килобайт; по отдельности он в лимите, но на него
+	// ссылаются все страницы
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write([]byte("BT (" + strings.Repeat("a", 1<<20) + ") Tj ET"))
+	zw.Close()
+	objects := []string{"<< /Type /Catalog /Pages 2 0 R >>", ""}
+	var kids []string
+	for i := 0; i < 10; i++ {
+		kids = append(kids, strconv.Itoa(len(objects)+1)+" 0 R")
+		objects = append(objects, "<< /Type /Page /Contents 13 0 R >>")
+	}
+	objects[1] = "<< /Type /Pages /Kids [" + strings.Join(kids, " ") + "] >>"
+	objects = append(objects, "<< /Length "+strconv.Itoa(compressed.Len())+" /Filter /FlateDecode >>\nstream\n"+compressed.String()+"\nendstream")
+	data := testPDF("<< /Root 1 0 R >>", objects...)
+	if _, err := extractPDF(bytes.NewReader(data), int64(len(data))); !errors.Is(err, errArchiveLimit) {
+		t.Fatalf("ошибка %v, ожидалась errArchiveLimit", err)
+	}
+}
+
+func TestParseToUnicodeEntriesLimit(t *testing.T) {
+	cmap := []byte("1 begincodespacerange <0000> <FFFF> endcodespacerange\n" +
+		"2 beginbfrange <0000> <FFFF> <0041> <0000> <FFFF> <0042> endbfrange\n" +
+		"1 beginbfchar <0001> <0043> endbfchar")
+	entries := pdfMaxCMapEntries - 10
+	mapping, codeLen := parseToUnicode(cmap, &entries)
+	if codeLen != 2 {
+		t.Fatalf("длина кода %d", codeLen)
+	}
+	if len(mapping) != 10 || entries != pdfMaxCMapEntries {
+		t.Fatalf("записей %d, счётчик %d: ожидалось 10 и %d", len(mapping), entries, pdfMaxCMapEntries)
+	}
+}
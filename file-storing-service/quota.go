@@ -0,0 +1,150 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Квоты не дают заполнить диск скриптом через /upload:
+//
+//	QUOTA_MAX_SUBMISSIONS      — работ одного студента по одному заданию (по умолчанию 20)
+//	QUOTA_STUDENT_MAX_BYTES    — суммарный размер работ студента (по умолчанию 200 МБ)
+//	QUOTA_ASSIGNMENT_MAX_BYTES — суммарный размер работ по заданию (по умолчанию 5 ГБ)
+//
+// Размер считается по загруженным файлам, в том числе совпавшим с уже сохранёнными.
+// Незавершённая загрузка tus резервирует место под работу и свой Upload-Length, пока не
+// завершится или не истечёт. Окончательная проверка квоты и запись работы или загрузки
+// идут в одной транзакции, поэтому параллельные загрузки не превысят квоту вместе.
+var (
+	quotaMaxSubmissions     = envInt64("QUOTA_MAX_SUBMISSIONS", 20)
+	quotaStudentMaxBytes    = envInt64("QUOTA_STUDENT_MAX_BYTES", 200<<20)
+	quotaAssignmentMaxBytes = envInt64("QUOTA_ASSIGNMENT_MAX_BYTES", 5<<30)
+)
+
+// quotaUsage — сколько уже занято студентом в задании, студентом всего и всеми в задании.
+type quotaUsage struct {
+	Submissions     int64
+	StudentBytes    int64
+	AssignmentBytes int64
+}
+
+// quotaItems — работы и незавершённые загрузки tus, кроме загрузки с ID из первого
+// параметра: её как раз регистрируют работой, и резерв больше не нужен.
+const quotaItems = `(
+	SELECT student_id, assignment_id, size FROM files
+	UNION ALL
+	SELECT student_id, assignment_id, upload_length FROM tus_uploads WHERE file_id IS NULL AND id != ?
+)`
+
+// dbQuerier — *sql.DB или *sql.Tx.
+type dbQuerier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func loadQuotaUsage(q dbQuerier, studentID string, assignmentID string, exceptTusID string) (quotaUsage, error) {
+	var usage quotaUsage
+	err := q.QueryRow(`SELECT COUNT(*) FROM `+quotaItems+` WHERE student_id = ? AND assignment_id = ?`, exceptTusID, studentID, assignmentID).
+		Scan(&usage.Submissions)
+	if err != nil {
+		return usage, err
+	}
+	err = q.QueryRow(`SELECT COALESCE(SUM(size), 0) FROM `+quotaItems+` WHERE student_id = ?`, exceptTusID, studentID).Scan(&usage.StudentBytes)
+	if err != nil {
+		return usage, err
+	}
+	err = q.QueryRow(`SELECT COALESCE(SUM(size), 0) FROM `+quotaItems+` WHERE assignment_id = ?`, exceptTusID, assignmentID).Scan(&usage.AssignmentBytes)
+	return usage, err
+}
+
+// remainingBytes — сколько байт ещё можно загрузить студенту в задание.
+func (q quotaUsage) remainingBytes() int64 {
+	return max(0, min(quotaStudentMaxBytes-q.StudentBytes, quotaAssignmentMaxBytes-q.AssignmentBytes))
+}
+
+func quotaError(code int, quota string, message string, limit int64, used int64) *uploadError {
+	return &uploadError{code: code, body: map[string]interface{}{
+		"error":     message,
+		"quota":     quota,
+		"limit":     limit,
+		"used":      used,
+		"remaining": max(0, limit-used),
+	}}
+}
+
+// checkQuota проверяет, можно ли студенту загрузить в задание ещё size байт. Превышение
+// числа работ — 429, объёма — 413; в ответе остаток по квоте, которая не пустила работу.
+// Резерв загрузки tus с ID exceptTusID не учитывается.
+func checkQuota(q dbQuerier, studentID string, assignmentID string, exceptTusID string, size int64) *uploadError {
+	usage, err := loadQuotaUsage(q, studentID, assignmentID, exceptTusID)
+	if err != nil {
+		fmt.Println("Ошибка при подсчёте квот:", err)
+		return &uploadError{code: http.StatusInternalServerError, message: `Ошибка при запросе к БД`}
+	}
+	return usage.check(assignmentID, size)
+}
+
+func (q quotaUsage) check(assignmentID string, size int64) *uploadError {
+	if q.Submissions >= quotaMaxSubmissions {
+		message := fmt.Sprintf("Достигнут лимит работ по заданию %s: %d из %d", assignmentID, q.Submissions, quotaMaxSubmissions)
+		return quotaError(http.StatusTooManyRequests, "submissions", message, quotaMaxSubmissions, q.Submissions)
+	}
+	if q.StudentBytes+size > quotaStudentMaxBytes {
+		message := fmt.Sprintf("Превышена квота студента: осталось %d байт из %d",
+			max(0, quotaStudentMaxBytes-q.StudentBytes), quotaStudentMaxBytes)
+		return quotaError(http.StatusRequestEntityTooLarge, "student_bytes", message, quotaStudentMaxBytes, q.StudentBytes)
+	}
+	if q.AssignmentBytes+size > quotaAssignmentMaxBytes {
+		message := fmt.Sprintf("Превышена квота задания %s: осталось %d байт из %d",
+			assignmentID, max(0, quotaAssignmentMaxBytes-q.AssignmentBytes), quotaAssignmentMaxBytes)
+		return quotaError(http.StatusRequestEntityTooLarge, "assignment_bytes", message, quotaAssignmentMaxBytes, q.AssignmentBytes)
+	}
+	return nil
+}
+
+// AssignmentUsage — работы студента по одному заданию.
+type AssignmentUsage struct {
+	AssignmentID         string `json:"assignment_id"`
+	Submissions          int64  `json:"submissions"`
+	SubmissionsLimit     int64  `json:"submissions_limit"`
+	SubmissionsRemaining int64  `json:"submissions_remaining"`
+	Bytes                int64  `json:"bytes"`
+}
+
+// studentUsageHandler — GET /students/{id}/usage: сколько студент занял и сколько осталось.
+// Незавершённые загрузки tus учитываются так же, как в квотах.
+func studentUsageHandler(w http.ResponseWriter, r *http.Request, studentID string) {
+	query := `
+	SELECT assignment_id, COUNT(*), COALESCE(SUM(size), 0)
+	FROM ` + quotaItems + ` WHERE student_id = ?
+	GROUP BY assignment_id ORDER BY assignment_id ASC
+	`
+	rows, err := db.Query(query, "", studentID)
+	if err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	assignments := []AssignmentUsage{}
+	var submissions, bytes int64
+	for rows.Next() {
+		a := AssignmentUsage{SubmissionsLimit: quotaMaxSubmissions}
+		if err := rows.Scan(&a.AssignmentID, &a.Submissions, &a.Bytes); err != nil {
+			continue
+		}
+		a.SubmissionsRemaining = max(0, quotaMaxSubmissions-a.Submissions)
+		submissions += a.Submissions
+		bytes += a.Bytes
+		assignments = append(assignments, a)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"student_id":      studentID,
+		"submissions":     submissions,
+		"bytes_used":      bytes,
+		"bytes_limit":     quotaStudentMaxBytes,
+		"bytes_remaining": max(0, quotaStudentMaxBytes-bytes),
+		"assignments":     assignments,
+	})
+}
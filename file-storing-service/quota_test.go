@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// setQuotas подменяет квоты на время теста.
+func setQuotas(t *testing.T, submissions int64, studentBytes int64, assignmentBytes int64) {
+	t.Helper()
+	saved := []int64{quotaMaxSubmissions, quotaStudentMaxBytes, quotaAssignmentMaxBytes}
+	quotaMaxSubmissions, quotaStudentMaxBytes, quotaAssignmentMaxBytes = submissions, studentBytes, assignmentBytes
+	t.Cleanup(func() {
+		quotaMaxSubmissions, quotaStudentMaxBytes, quotaAssignmentMaxBytes = saved[0], saved[1], saved[2]
+	})
+}
+
+func TestQuotaUsage(t *testing.T) {
+	openTestDB(t)
+	_, err := db.Exec(`
+	INSERT INTO files (student_id, assignment_id, file_path, size) VALUES
+		('std_1', 'hw1', 'a', 100), ('std_1', 'hw1', 'b', 200), ('std_1', 'hw2', 'c', 1000),
+		('std_2', 'hw1', 'd', 5000);
+	INSERT INTO tus_uploads (id, upload_length, filename, student_id, assignment_id, file_id) VALUES
+		('pending', 50, 'main.py', 'std_1', 'hw1', NULL),
+		('finished', 100, 'main.py', 'std_1', 'hw1', 1),
+		('other', 7, 'main.py', 'std_2', 'hw2', NULL);
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name   string
+		except string
+		want   quotaUsage
+	}{
+		// Завершённая загрузка уже учтена работой, незавершённая резервирует Upload-Length
+		{"с незавершённой загрузкой", "", quotaUsage{Submissions: 3, StudentBytes: 1350, AssignmentBytes: 5350}},
+		// Загрузку pending как раз регистрируют работой: её резерв не считается
+		{"без регистрируемой загрузки", "pending", quotaUsage{Submissions: 2, StudentBytes: 1300, AssignmentBytes: 5300}},
+	}
+	for _, c := range cases {
+		got, err := loadQuotaUsage(db, "std_1", "hw1", c.except)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.want {
+			t.Errorf("%s: получено %+v, ожидалось %+v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestQuotaCheck(t *testing.T) {
+	setQuotas(t, 3, 1000, 5000)
+	cases := []struct {
+		name  string
+		usage quotaUsage
+		size  int64
+		code  int
+		quota string
+	}{
+		{"в пределах квот", quotaUsage{Submissions: 2, StudentBytes: 900, AssignmentBytes: 4900}, 100, 0, ""},
+		{"число работ", quotaUsage{Submissions: 3}, 1, http.StatusTooManyRequests, "submissions"},
+		{"объём студента", quotaUsage{Submissions: 1, StudentBytes: 900}, 101, http.StatusRequestEntityTooLarge, "student_bytes"},
+		{"объём задания", quotaUsage{Submissions: 1, AssignmentBytes: 4950}, 51, http.StatusRequestEntityTooLarge, "assignment_bytes"},
+	}
+	for _, c := range cases {
+		uerr := c.usage.check("hw1", c.size)
+		if c.code == 0 {
+			if uerr != nil {
+				t.Errorf("%s: отказ %d %v", c.name, uerr.code, uerr.body)
+			}
+			continue
+		}
+		if uerr == nil || uerr.code != c.code || uerr.body["quota"] != c.quota {
+			t.Errorf("%s: получено %+v, ожидался код %d по квоте %s", c.name, uerr, c.code, c.quota)
+		}
+	}
+}
+
+// Параллельные загрузки проверяют квоту в той же транзакции, что и запись работы,
+// поэтому вместе не превышают её.
+func TestStoreUploadConcurrentQuota(t *testing.T) {
+	openTestStorage(t)
+	setQuotas(t, 3, 1<<20, 1<<20)
+	// Незавершённая загрузка tus занимает одно место из трёх
+	_, err := db.Exec(`INSERT INTO tus_uploads (id, upload_length, filename, student_id, assignment_id) VALUES ('pending', 10, 'main.py', 'std_1', 'hw1')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	codes := make([]int, 10)
+	var wg sync.WaitGroup
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			content := fmt.Sprintf("print(%d)\n", i)
+			_, uerr := storeUpload(upload{
+				file:         strings.NewReader(content),
+				filename:     "main.py",
+				size:         int64(len(content)),
+				studentID:    "std_1",
+				assignmentID: "hw1",
+				submittedAt:  time.Now(),
+			})
+			if uerr != nil {
+				codes[i] = uerr.code
+			}
+		}()
+	}
+	wg.Wait()
+
+	accepted, rejected := 0, 0
+	for _, code := range codes {
+		switch code {
+		case 0:
+			accepted++
+		case http.StatusTooManyRequests:
+			rejected++
+		default:
+			t.Errorf("неожиданный код %d", code)
+		}
+	}
+	var count int
+	db.QueryRow(`SELECT COUNT(*) FROM files WHERE student_id = 'std_1' AND assignment_id = 'hw1'`).Scan(&count)
+	if accepted != 2 || rejected != 8 || count != 2 {
+		t.Fatalf("принято %d, отклонено %d, работ в БД %d; ожидалось 2, 8 и 2", accepted, rejected, count)
+	}
+
+	// Резерв завершаемой загрузки заменяется работой, а не считается дважды
+	content := "print('tus')\n"
+	_, uerr := storeUpload(upload{file: strings.NewReader(content), filename: "main.py", size: int64(len(content)),
+		studentID: "std_1", assignmentID: "hw1", submittedAt: time.Now(), tusID: "pending"})
+	if uerr != nil {
+		t.Fatalf("завершение загрузки tus в пределах квоты: %d %s", uerr.code, uerr.message)
+	}
+}
@@ -0,0 +1,522 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"shared/pagination"
+)
+
+// Курсы, группы и списки студентов:
+//
+//	GET    /courses                              — список курсов
+//	POST   /courses                              — создать курс
+//	GET    /courses/{id}                         — курс
+//	GET    /courses/{id}/students[?group_id=…]   — записанные на курс студенты
+//	POST   /courses/{id}/roster[?replace=true]   — загрузить список курса из CSV
+//	DELETE /courses/{id}/students/{student_id}   — отчислить студента с курса
+//	GET    /groups                               — список групп
+//	GET    /groups/{id}                          — группа и её студенты
+//	GET    /students/{id}                        — студент, его группа и курсы
+//
+// Группа — учебная группа студента, общая для всех курсов. Если на курс, к которому
+// относится задание, загружен список, работы по заданию принимаются только от студентов
+// из списка: так опечатка в student_id не превращает одного студента в двух.
+type Course struct {
+	ID        string      `json:"id"`
+	Title     string      `json:"title"`
+	Students  int         `json:"students"`
+	CreatedAt interface{} `json:"created_at"`
+}
+
+type Group struct {
+	ID       string    `json:"id"`
+	Title    string    `json:"title"`
+	Students []Student `json:"students,omitempty"`
+	Count    int       `json:"student_count"`
+}
+
+type Student struct {
+	ID      string   `json:"student_id"`
+	Name    string   `json:"name"`
+	GroupID string   `json:"group_id"`
+	Courses []string `json:"courses,omitempty"`
+}
+
+// maxRosterSize — предел размера CSV со списком курса.
+const maxRosterSize = 10 << 20
+
+// backfillCourses заводит курсы, которые указаны в заданиях, созданных до появления курсов.
+func backfillCourses() {
+	query := `
+	INSERT OR IGNORE INTO courses (id, title)
+	SELECT DISTINCT course, course FROM assignments WHERE course != ''
+	`
+	result, err := db.Exec(query)
+	if err != nil {
+		fmt.Println("Ошибка при создании курсов заданий:", err)
+		return
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		fmt.Printf("Созданы курсы заданий: %d\n", n)
+	}
+}
+
+func courseExists(id string) (bool, error) {
+	var n int
+	err := db.QueryRow(`SELECT COUNT(*) FROM courses WHERE id = ?`, id).Scan(&n)
+	return n > 0, err
+}
+
+// checkEnrolment проверяет студента, сдающего работу по заданию курса courseID (пусто —
+// задание без курса). Пока не загружен ни один список, работы принимаются от любых
+// студентов. После этого студент должен быть в каком-нибудь списке, а если список есть
+// у самого курса — в нём.
+func checkEnrolment(courseID string, studentID string) *uploadError {
+	var students, known int
+	query := `SELECT COUNT(*), COALESCE(SUM(id = ?), 0) FROM students`
+	if err := db.QueryRow(query, studentID).Scan(&students, &known); err != nil {
+		fmt.Println("Ошибка при проверке списков курсов:", err)
+		return &uploadError{code: http.StatusInternalServerError, message: `Ошибка при запросе к БД`}
+	}
+	if students > 0 && known == 0 {
+		return &uploadError{code: http.StatusForbidden, message: fmt.Sprintf(`Студента %s нет ни в одном списке курса`, studentID)}
+	}
+	if courseID == "" {
+		return nil
+	}
+	var roster, enrolled int
+	query = `SELECT COUNT(*), COALESCE(SUM(student_id = ?), 0) FROM enrolments WHERE course_id = ?`
+	if err := db.QueryRow(query, studentID, courseID).Scan(&roster, &enrolled); err != nil {
+		fmt.Println("Ошибка при проверке списка курса:", err)
+		return &uploadError{code: http.StatusInternalServerError, message: `Ошибка при запросе к БД`}
+	}
+	if roster > 0 && enrolled == 0 {
+		return &uploadError{code: http.StatusForbidden, message: fmt.Sprintf(`Студент %s не записан на курс %s`, studentID, courseID)}
+	}
+	return nil
+}
+
+func coursesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch r.Method {
+	case http.MethodGet:
+		rows, err := db.Query(`SELECT ` + courseColumns + ` FROM courses c ORDER BY c.id ASC`)
+		if err != nil {
+			http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+			return
+		}
+		defer rows.Close()
+		courses := []Course{}
+		for rows.Next() {
+			var c Course
+			if err := rows.Scan(&c.ID, &c.Title, &c.Students, &c.CreatedAt); err != nil {
+				continue
+			}
+			courses = append(courses, c)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"courses": courses,
+		})
+	case http.MethodPost:
+		var c Course
+		if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+			http.Error(w, `Ошибка при парсинге JSON`, http.StatusBadRequest)
+			return
+		}
+		c.Title = strings.TrimSpace(c.Title)
+		if c.ID == "" || strings.ContainsAny(c.ID, "/?#") || c.Title == "" {
+			http.Error(w, `id и title обязательны, id не должен содержать / ? #`, http.StatusBadRequest)
+			return
+		}
+		_, err := db.Exec(`INSERT INTO courses (id, title) VALUES (?, ?)`, c.ID, c.Title)
+		if err != nil && strings.Contains(err.Error(), "UNIQUE") {
+			http.Error(w, `Курс с таким id уже есть`, http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, `Ошибка при сохранении данных в БД`, http.StatusInternalServerError)
+			return
+		}
+		fmt.Printf("Создан курс %s: %s\n", c.ID, c.Title)
+		w.WriteHeader(http.StatusCreated)
+		writeCourse(w, c.ID)
+	default:
+		http.Error(w, "Only GET and POST methods are allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// courseColumns — курс и число записанных на него студентов.
+const courseColumns = `c.id, c.title, (SELECT COUNT(*) FROM enrolments e WHERE e.course_id = c.id), c.created_at`
+
+func writeCourse(w http.ResponseWriter, id string) {
+	var c Course
+	err := db.QueryRow(`SELECT `+courseColumns+` FROM courses c WHERE c.id = ?`, id).Scan(&c.ID, &c.Title, &c.Students, &c.CreatedAt)
+	if err == sql.ErrNoRows {
+		http.Error(w, `Курс не найден`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(c)
+}
+
+func courseHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	parts := strings.Split(r.URL.Path[len("/courses/"):], "/")
+	id := parts[0]
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		writeCourse(w, id)
+	case len(parts) == 2 && parts[1] == "students" && r.Method == http.MethodGet:
+		courseStudentsHandler(w, r, id)
+	case len(parts) == 2 && parts[1] == "roster" && r.Method == http.MethodPost:
+		rosterImportHandler(w, r, id)
+	case len(parts) == 3 && parts[1] == "students" && r.Method == http.MethodDelete:
+		result, err := db.Exec(`DELETE FROM enrolments WHERE course_id = ? AND student_id = ?`, id, parts[2])
+		if err != nil {
+			http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			http.Error(w, `Студент не записан на курс`, http.StatusNotFound)
+			return
+		}
+		fmt.Printf("Студент %s отчислен с курса %s\n", parts[2], id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func courseStudentsHandler(w http.ResponseWriter, r *http.Request, courseID string) {
+	if ok, err := courseExists(courseID); err != nil || !ok {
+		http.Error(w, `Курс не найден`, http.StatusNotFound)
+		return
+	}
+	filters := pagination.Filters{}
+	filters.Add("e.course_id = ?", courseID)
+	if v := r.URL.Query().Get("group_id"); v != "" {
+		filters.Add("COALESCE(s.group_id, '') = ?", v)
+	}
+	query := `
+	SELECT e.student_id, COALESCE(s.name, ''), COALESCE(s.group_id, '')
+	FROM enrolments e LEFT JOIN students s ON s.id = e.student_id
+	` + filters.Where() + ` ORDER BY e.student_id ASC`
+	students, err := queryStudents(query, filters.Args...)
+	if err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"course_id": courseID,
+		"students":  students,
+	})
+}
+
+func queryStudents(query string, args ...interface{}) ([]Student, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	students := []Student{}
+	for rows.Next() {
+		var s Student
+		if err := rows.Scan(&s.ID, &s.Name, &s.GroupID); err != nil {
+			return nil, err
+		}
+		students = append(students, s)
+	}
+	return students, rows.Err()
+}
+
+// rosterRow — строка списка курса.
+type rosterRow struct {
+	studentID string
+	name      string
+	groupID   string
+}
+
+// RosterError — ошибка в строке CSV.
+type RosterError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// parseRoster читает CSV с заголовком: обязательная колонка student_id и необязательные
+// name и group. Разделитель — запятая или точка с запятой (так сохраняет Excel).
+func parseRoster(r io.Reader) ([]rosterRow, []RosterError, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxRosterSize+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(data) > maxRosterSize {
+		return nil, nil, fmt.Errorf("список больше %d байт", maxRosterSize)
+	}
+	text := strings.TrimPrefix(string(data), "\ufeff")
+	firstLine, _, _ := strings.Cut(text, "\n")
+	reader := csv.NewReader(strings.NewReader(text))
+	if strings.Count(firstLine, ";") > strings.Count(firstLine, ",") {
+		reader.Comma = ';'
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, errors.New("список пуст")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("некорректный CSV: %v", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	idColumn, ok := columns["student_id"]
+	if !ok {
+		return nil, nil, errors.New("в заголовке нет колонки student_id")
+	}
+	field := func(record []string, column string) string {
+		if i, ok := columns[column]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	var rows []rosterRow
+	var rowErrors []RosterError
+	seen := map[string]int{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("некорректный CSV: %v", err)
+		}
+		// Пустые строки csv пропускает сам, поэтому номер строки берётся из reader
+		line, _ := reader.FieldPos(0)
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		row := rosterRow{studentID: field(record, "student_id"), name: field(record, "name"), groupID: field(record, "group")}
+		switch {
+		case idColumn >= len(record) || row.studentID == "":
+			rowErrors = append(rowErrors, RosterError{Line: line, Error: "пустой student_id"})
+		case strings.ContainsAny(row.studentID, " /?#"):
+			rowErrors = append(rowErrors, RosterError{Line: line, Error: fmt.Sprintf("некорректный student_id %q", row.studentID)})
+		case seen[row.studentID] > 0:
+			rowErrors = append(rowErrors, RosterError{Line: line, Error: fmt.Sprintf("student_id %s уже был в строке %d", row.studentID, seen[row.studentID])})
+		default:
+			seen[row.studentID] = line
+			rows = append(rows, row)
+		}
+	}
+	return rows, rowErrors, nil
+}
+
+// rosterImportHandler загружает список курса из CSV (тело запроса или поле file формы).
+// Студенты и группы создаются или обновляются, студенты записываются на курс; с replace=true
+// студенты, которых нет в списке, отчисляются. При ошибке в любой строке не меняется ничего.
+func rosterImportHandler(w http.ResponseWriter, r *http.Request, courseID string) {
+	if ok, err := courseExists(courseID); err != nil || !ok {
+		http.Error(w, `Курс не найден`, http.StatusNotFound)
+		return
+	}
+	body := io.Reader(r.Body)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, `Файл не найден в запросе`, http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+	rows, rowErrors, err := parseRoster(body)
+	if err != nil {
+		http.Error(w, `Ошибка в списке: `+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(rowErrors) > 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error":  "Список не загружен: ошибки в строках",
+			"errors": rowErrors,
+		})
+		return
+	}
+	if len(rows) == 0 {
+		http.Error(w, `Список пуст`, http.StatusBadRequest)
+		return
+	}
+	enrolled, removed, err := importRoster(courseID, rows, r.URL.Query().Get("replace") == "true")
+	if err != nil {
+		fmt.Println("Ошибка при загрузке списка курса:", err)
+		http.Error(w, `Ошибка при сохранении данных в БД`, http.StatusInternalServerError)
+		return
+	}
+	fmt.Printf("Загружен список курса %s: %d студентов, записано %d, отчислено %d\n", courseID, len(rows), enrolled, removed)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"course_id": courseID,
+		"imported":  len(rows),
+		"enrolled":  enrolled,
+		"removed":   removed,
+	})
+}
+
+// importRoster сохраняет список в одной транзакции и возвращает, сколько студентов записано
+// на курс заново и сколько отчислено.
+func importRoster(courseID string, rows []rosterRow, replace bool) (int64, int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+	var enrolled, removed int64
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if row.groupID != "" {
+			if _, err := tx.Exec(`INSERT OR IGNORE INTO student_groups (id, title) VALUES (?, ?)`, row.groupID, row.groupID); err != nil {
+				return 0, 0, err
+			}
+		}
+		// Пустые name и group в списке не затирают уже известные
+		query := `
+		INSERT INTO students (id, name, group_id) VALUES (?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			name = CASE WHEN excluded.name != '' THEN excluded.name ELSE students.name END,
+			group_id = CASE WHEN excluded.group_id != '' THEN excluded.group_id ELSE students.group_id END
+		`
+		if _, err := tx.Exec(query, row.studentID, row.name, row.groupID); err != nil {
+			return 0, 0, err
+		}
+		result, err := tx.Exec(`INSERT OR IGNORE INTO enrolments (course_id, student_id) VALUES (?, ?)`, courseID, row.studentID)
+		if err != nil {
+			return 0, 0, err
+		}
+		n, _ := result.RowsAffected()
+		enrolled += n
+		ids = append(ids, row.studentID)
+	}
+	if replace {
+		// Отчисляются студенты курса, которых нет в новом списке
+		list, _ := json.Marshal(ids)
+		query := `DELETE FROM enrolments WHERE course_id = ? AND student_id NOT IN (SELECT value FROM json_each(?))`
+		result, err := tx.Exec(query, courseID, string(list))
+		if err != nil {
+			return 0, 0, err
+		}
+		removed, _ = result.RowsAffected()
+	}
+	return enrolled, removed, tx.Commit()
+}
+
+func groupsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	query := `
+	SELECT g.id, g.title, (SELECT COUNT(*) FROM students s WHERE s.group_id = g.id)
+	FROM student_groups g ORDER BY g.id ASC
+	`
+	rows, err := db.Query(query)
+	if err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	groups := []Group{}
+	for rows.Next() {
+		var g Group
+		if err := rows.Scan(&g.ID, &g.Title, &g.Count); err != nil {
+			continue
+		}
+		groups = append(groups, g)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"groups": groups,
+	})
+}
+
+func groupHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	var g Group
+	err := db.QueryRow(`SELECT id, title FROM student_groups WHERE id = ?`, r.URL.Path[len("/groups/"):]).Scan(&g.ID, &g.Title)
+	if err == sql.ErrNoRows {
+		http.Error(w, `Группа не найдена`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
+	g.Students, err = queryStudents(`SELECT id, name, group_id FROM students WHERE group_id = ? ORDER BY id ASC`, g.ID)
+	if err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
+	g.Count = len(g.Students)
+	json.NewEncoder(w).Encode(g)
+}
+
+// studentsHandler — /students/{id} и /students/{id}/usage.
+func studentsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	studentID, sub, _ := strings.Cut(r.URL.Path[len("/students/"):], "/")
+	switch {
+	case studentID == "":
+		http.NotFound(w, r)
+	case sub == "usage":
+		studentUsageHandler(w, r, studentID)
+	case sub == "":
+		studentHandler(w, studentID)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func studentHandler(w http.ResponseWriter, studentID string) {
+	w.Header().Set("Content-Type", "application/json")
+	s := Student{ID: studentID}
+	err := db.QueryRow(`SELECT name, group_id FROM students WHERE id = ?`, studentID).Scan(&s.Name, &s.GroupID)
+	if err == sql.ErrNoRows {
+		http.Error(w, `Студент не найден`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
+	rows, err := db.Query(`SELECT course_id FROM enrolments WHERE student_id = ? ORDER BY course_id ASC`, studentID)
+	if err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	s.Courses = []string{}
+	for rows.Next() {
+		var course string
+		if err := rows.Scan(&course); err == nil {
+			s.Courses = append(s.Courses, course)
+		}
+	}
+	json.NewEncoder(w).Encode(s)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// openTestDB подключает пакет к новой БД во временном каталоге.
+func openTestDB(t *testing.T) {
+	t.Helper()
+	openDB(filepath.Join(t.TempDir(), "files.db"))
+	t.Cleanup(func() { db.Close() })
+	if _, err := migrateUp(0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckAssignmentEnrolment(t *testing.T) {
+	openTestDB(t)
+	_, err := db.Exec(`INSERT INTO assignments (id, title, course) VALUES ('hw1', 'Без курса', ''), ('hw2', 'Алгоритмы', 'algo')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(assignmentID string, studentID string, want int) {
+		t.Helper()
+		_, uerr := checkAssignment(assignmentID, studentID, "main.py", time.Now())
+		got := 0
+		if uerr != nil {
+			got = uerr.code
+		}
+		if got != want {
+			t.Errorf("checkAssignment(%s, %s): код %d, ожидался %d", assignmentID, studentID, got, want)
+		}
+	}
+
+	// Пока списков нет, принимаются любые студенты
+	check("hw1", "std_13", 0)
+	check("hw2", "std_13", 0)
+
+	if _, _, err := importRoster("other", []rosterRow{{studentID: "std_0013"}, {studentID: "std_0042"}}, false); err != nil {
+		t.Fatal(err)
+	}
+	// Задание без курса: опечатка в student_id больше не проходит
+	check("hw1", "std_0013", 0)
+	check("hw1", "std_13", http.StatusForbidden)
+	// У курса задания списка нет: достаточно быть в каком-нибудь списке
+	check("hw2", "std_0042", 0)
+	check("hw2", "std_13", http.StatusForbidden)
+
+	if _, _, err := importRoster("algo", []rosterRow{{studentID: "std_0013"}}, false); err != nil {
+		t.Fatal(err)
+	}
+	check("hw2", "std_0013", 0)
+	check("hw2", "std_0042", http.StatusForbidden)
+}
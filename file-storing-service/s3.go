@@ -0,0 +1,288 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// s3Storage хранит объекты в S3-совместимом хранилище (AWS S3, MinIO). Запросы подписываются
+// AWS Signature V4, адреса — в path-style (endpoint/bucket/key), как принято у MinIO.
+//
+// Настройка: S3_ENDPOINT (например, http://minio:9000), S3_BUCKET, S3_ACCESS_KEY,
+// S3_SECRET_KEY и S3_REGION (по умолчанию us-east-1).
+type s3Storage struct {
+	endpoint  string
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+func newS3Storage() (*s3Storage, error) {
+	s := &s3Storage{
+		endpoint:  strings.TrimSuffix(os.Getenv("S3_ENDPOINT"), "/"),
+		bucket:    os.Getenv("S3_BUCKET"),
+		region:    os.Getenv("S3_REGION"),
+		accessKey: os.Getenv("S3_ACCESS_KEY"),
+		secretKey: os.Getenv("S3_SECRET_KEY"),
+		client:    &http.Client{Timeout: 5 * time.Minute},
+	}
+	if s.region == "" {
+		s.region = "us-east-1"
+	}
+	if s.endpoint == "" || s.bucket == "" || s.accessKey == "" || s.secretKey == "" {
+		return nil, errors.New("нужны S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY и S3_SECRET_KEY")
+	}
+	if _, err := url.Parse(s.endpoint); err != nil {
+		return nil, fmt.Errorf("некорректный S3_ENDPOINT: %v", err)
+	}
+	return s, nil
+}
+
+func (s *s3Storage) Put(key string, r io.ReadSeeker) error {
+	resp, err := s.do(http.MethodPut, key, nil, r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp, key)
+	}
+	return nil
+}
+
+func (s *s3Storage) Open(key string) (io.ReadCloser, error) {
+	resp, err := s.do(http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, errObjectNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, s3Error(resp, key)
+	}
+	return resp.Body, nil
+}
+
+func (s *s3Storage) Exists(key string) (bool, error) {
+	resp, err := s.do(http.MethodHead, key, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, fmt.Errorf("S3 HEAD %s: %s", key, resp.Status)
+}
+
+type s3ListResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *s3Storage) List(prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.do(http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := s3Error(resp, prefix)
+			resp.Body.Close()
+			return nil, err
+		}
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range result.Contents {
+			keys = append(keys, c.Key)
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// ensureBucket создаёт бакет, если его ещё нет (удобно для свежего MinIO).
+func (s *s3Storage) ensureBucket() error {
+	resp, err := s.do(http.MethodHead, "", nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	resp, err = s.do(http.MethodPut, "", nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error(resp, s.bucket)
+	}
+	return nil
+}
+
+func s3Error(resp *http.Response, key string) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("S3 %s %s: %s %s", resp.Request.Method, key, resp.Status, strings.TrimSpace(string(body)))
+}
+
+// do отправляет подписанный запрос к объекту key (пустой key — к самому бакету).
+func (s *s3Storage) do(method string, key string, query url.Values, body io.ReadSeeker) (*http.Response, error) {
+	payloadHash := sha256.New()
+	var size int64
+	if body != nil {
+		if _, err := body.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		n, err := io.Copy(payloadHash, body)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := body.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		size = n
+	}
+	rawPath := "/" + s3Escape(s.bucket, false)
+	if key != "" {
+		rawPath += "/" + s3Escape(key, false)
+	}
+	u, err := url.Parse(s.endpoint + rawPath)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = canonicalQuery(query)
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	// Пустое тело — http.NoBody: иначе запрос уходит с chunked-кодированием без
+	// Content-Length, а S3 и MinIO такой PUT отклоняют
+	if body != nil && size > 0 {
+		req.Body = io.NopCloser(body)
+		req.ContentLength = size
+	} else if body != nil {
+		req.Body = http.NoBody
+	}
+	s.sign(req, rawPath, hex.EncodeToString(payloadHash.Sum(nil)), time.Now().UTC())
+	return s.client.Do(req)
+}
+
+// sign добавляет заголовок Authorization по AWS Signature V4.
+func (s *s3Storage) sign(req *http.Request, rawPath string, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		rawPath,
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Escape кодирует строку по правилам SigV4: без изменений остаются только A-Z, a-z, 0-9
+// и -._~, а слеш — если это не значение параметра запроса.
+func s3Escape(s string, escapeSlash bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '.' || c == '_' || c == '~' || (c == '/' && !escapeSlash) {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, s3Escape(k, true)+"="+s3Escape(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
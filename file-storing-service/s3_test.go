@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 — минимальное S3-совместимое хранилище в памяти: один бакет, PUT, GET, HEAD
+// и ListObjectsV2 по две записи на страницу. Как и S3, отклоняет PUT без
+// Content-Length и запросы с неверным x-amz-content-sha256.
+type fakeS3 struct {
+	bucket  string
+	mu      sync.Mutex
+	objects map[string][]byte
+	// fail — код ответа на все запросы, если задан.
+	fail int
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail != 0 {
+		w.WriteHeader(f.fail)
+		fmt.Fprint(w, "<Error><Code>InternalError</Code></Error>")
+		return
+	}
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=test-key/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	sum := sha256.Sum256(body)
+	if r.Header.Get("x-amz-content-sha256") != hex.EncodeToString(sum[:]) {
+		http.Error(w, "XAmzContentSHA256Mismatch", http.StatusBadRequest)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, "/"+f.bucket)
+	if path == "" && r.Method == http.MethodGet {
+		f.list(w, r)
+		return
+	}
+	if path == "" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	key := strings.TrimPrefix(path, "/")
+	switch r.Method {
+	case http.MethodPut:
+		if r.ContentLength < 0 || len(r.TransferEncoding) > 0 {
+			http.Error(w, "MissingContentLength", http.StatusLengthRequired)
+			return
+		}
+		f.objects[key] = body
+	case http.MethodGet, http.MethodHead:
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Write(data)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var keys []string
+	for key := range f.objects {
+		if strings.HasPrefix(key, q.Get("prefix")) && key > q.Get("continuation-token") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	truncated := len(keys) > 2
+	if truncated {
+		keys = keys[:2]
+	}
+	fmt.Fprint(w, "<ListBucketResult>")
+	for _, key := range keys {
+		fmt.Fprintf(w, "<Contents><Key>%s</Key></Contents>", key)
+	}
+	if truncated {
+		fmt.Fprintf(w, "<IsTruncated>true</IsTruncated><NextContinuationToken>%s</NextContinuationToken>", keys[len(keys)-1])
+	}
+	fmt.Fprint(w, "</ListBucketResult>")
+}
+
+func newTestS3(t *testing.T) (*s3Storage, *fakeS3) {
+	t.Helper()
+	fake := &fakeS3{bucket: "antiplague", objects: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	s := &s3Storage{endpoint: server.URL, bucket: fake.bucket, region: "us-east-1",
+		accessKey: "test-key", secretKey: "test-secret", client: server.Client()}
+	return s, fake
+}
+
+func TestS3Storage(t *testing.T) {
+	s, fake := newTestS3(t)
+	if err := s.ensureBucket(); err != nil {
+		t.Fatal(err)
+	}
+	objects := map[string]string{
+		"blobs/ab/abc/main.py":    "print(1)\n",
+		"blobs/ab/abc/lib/a b.py": "x = 'пробел в имени'\n",
+		"blobs/ab/abc/empty.txt":  "",
+		"blobs/cd/other.py":       "pass\n",
+	}
+	for key, content := range objects {
+		if err := s.Put(key, strings.NewReader(content)); err != nil {
+			t.Fatalf("Put(%s): %v", key, err)
+		}
+	}
+	for key, content := range objects {
+		rc, err := s.Open(key)
+		if err != nil {
+			t.Fatalf("Open(%s): %v", key, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		if string(data) != content {
+			t.Errorf("Open(%s) = %q, ожидалось %q", key, data, content)
+		}
+		if ok, err := s.Exists(key); !ok || err != nil {
+			t.Errorf("Exists(%s) = %v, %v", key, ok, err)
+		}
+	}
+	if _, ok := fake.objects["blobs/ab/abc/lib/a b.py"]; !ok {
+		t.Error("ключ с пробелом сохранён под другим именем")
+	}
+
+	keys, err := s.List("blobs/ab/abc/")
+	want := []string{"blobs/ab/abc/empty.txt", "blobs/ab/abc/lib/a b.py", "blobs/ab/abc/main.py"}
+	if err != nil || strings.Join(keys, ",") != strings.Join(want, ",") {
+		t.Fatalf("List = %v, %v; ожидалось %v", keys, err, want)
+	}
+
+	if _, err := s.Open("blobs/ab/abc/missing.py"); err != errObjectNotFound {
+		t.Fatalf("Open отсутствующего объекта: %v", err)
+	}
+	if ok, err := s.Exists("blobs/ab/abc/missing.py"); ok || err != nil {
+		t.Fatalf("Exists отсутствующего объекта = %v, %v", ok, err)
+	}
+}
+
+func TestS3StorageErrors(t *testing.T) {
+	s, fake := newTestS3(t)
+	fake.fail = http.StatusInternalServerError
+	if err := s.Put("blobs/a.py", strings.NewReader("x")); err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("Put: %v", err)
+	}
+	if _, err := s.Open("blobs/a.py"); err == nil || errors.Is(err, errObjectNotFound) {
+		t.Errorf("Open: %v", err)
+	}
+	if _, err := s.Exists("blobs/a.py"); err == nil {
+		t.Error("Exists: ошибка не возвращена")
+	}
+	if _, err := s.List("blobs/"); err == nil {
+		t.Error("List: ошибка не возвращена")
+	}
+
+	s.secretKey, s.accessKey = "wrong", "wrong"
+	fake.fail = 0
+	if err := s.Put("blobs/a.py", strings.NewReader("x")); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Put с чужим ключом: %v", err)
+	}
+
+	s.endpoint = "http://127.0.0.1:1"
+	if _, err := s.Open("blobs/a.py"); err == nil {
+		t.Error("Open недоступного хранилища: ошибка не возвращена")
+	}
+}
+
+func TestS3EmptyPutHasContentLength(t *testing.T) {
+	var got http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = *r
+	}))
+	defer server.Close()
+	s := &s3Storage{endpoint: server.URL, bucket: "b", region: "us-east-1", accessKey: "k", secretKey: "s", client: server.Client()}
+	if err := s.Put("empty.txt", bytes.NewReader(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if got.ContentLength != 0 || len(got.TransferEncoding) > 0 {
+		t.Fatalf("пустой PUT: Content-Length %d, Transfer-Encoding %v", got.ContentLength, got.TransferEncoding)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Результат проверки содержимого работы (колонка content_check).
+const (
+	contentText   = "text"
+	contentBinary = "binary"
+	contentNoText = "no_text" // документ без извлекаемого текста, например скан PDF
+)
+
+// sniffSize — сколько первых байтов файла проверяется при определении содержимого.
+const sniffSize = 8000
+
+// Сигнатуры распространённых бинарных форматов, которые студенты переименовывают в .py или .txt.
+var magicNumbers = []struct {
+	prefix []byte
+	name   string
+}{
+	{[]byte("\x7fELF"), "исполняемый файл ELF"},
+	{[]byte{0xCF, 0xFA, 0xED, 0xFE}, "исполняемый файл Mach-O"},
+	{[]byte{0xFE, 0xED, 0xFA, 0xCF}, "исполняемый файл Mach-O"},
+	{[]byte{0xCA, 0xFE, 0xBA, 0xBE}, "исполняемый файл Mach-O или class-файл Java"},
+	{[]byte("\x89PNG\r\n\x1a\n"), "изображение PNG"},
+	{[]byte{0xFF, 0xD8, 0xFF}, "изображение JPEG"},
+	{[]byte("GIF87a"), "изображение GIF"},
+	{[]byte("GIF89a"), "изображение GIF"},
+	{[]byte("%PDF-"), "документ PDF"},
+	{[]byte("PK\x03\x04"), "zip-архив или документ Office"},
+	{[]byte{0x1F, 0x8B}, "gzip-архив"},
+	{[]byte("7z\xBC\xAF\x27\x1C"), "архив 7z"},
+	{[]byte("Rar!\x1a\x07"), "архив RAR"},
+	{[]byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}, "документ Office 97-2003"},
+}
+
+// isPortableExecutable распознаёт исполняемый файл Windows. Двух байтов «MZ» мало — с них
+// начинается и обычный текст (MZ = 1), поэтому проверяется и заголовок PE: его смещение
+// записано в поле e_lfanew по адресу 0x3C.
+func isPortableExecutable(head []byte) bool {
+	if len(head) < 0x40 || !bytes.HasPrefix(head, []byte("MZ")) {
+		return false
+	}
+	offset := int64(binary.LittleEndian.Uint32(head[0x3C:]))
+	return offset+4 <= int64(len(head)) && bytes.Equal(head[offset:offset+4], []byte("PE\x00\x00"))
+}
+
+// sniffContent проверяет, что начало файла похоже на текст. Возвращает причину,
+// по которой файл считается бинарным, или пустую строку для текста.
+func sniffContent(head []byte) string {
+	for _, magic := range magicNumbers {
+		if bytes.HasPrefix(head, magic.prefix) {
+			return fmt.Sprintf("обнаружена сигнатура: %s", magic.name)
+		}
+	}
+	if isPortableExecutable(head) {
+		return "обнаружена сигнатура: исполняемый файл Windows"
+	}
+	if len(head) == 0 {
+		return ""
+	}
+	if looksLikeUTF16(head) {
+		return ""
+	}
+	nulls := bytes.Count(head, []byte{0})
+	if nulls*100 > len(head) {
+		return fmt.Sprintf("файл содержит %d нулевых байтов", nulls)
+	}
+	text := toUTF8(head, detectEncoding(head))
+	control, total := 0, 0
+	for len(text) > 0 {
+		r, size := utf8.DecodeRune(text)
+		text = text[size:]
+		total++
+		if r == utf8.RuneError && size == 1 && len(text) > 0 {
+			control++
+			continue
+		}
+		if unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t' && r != '\f' {
+			control++
+		}
+	}
+	if control*10 > total {
+		return "файл содержит слишком много управляющих символов"
+	}
+	return ""
+}
+
+// sniffFile проверяет содержимое уже сохранённого файла.
+func sniffFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head := make([]byte, sniffSize)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	return sniffContent(head[:n]), nil
+}
+
+// backfillContentCheck проверяет содержимое работ, загруженных до появления проверки,
+// и помечает бинарные, чтобы сервис анализа их пропускал.
+func backfillContentCheck() {
+	rows, err := db.Query(`SELECT id, file_path FROM files WHERE content_check IS NULL`)
+	if err != nil {
+		fmt.Println("Ошибка при запросе к БД", err)
+		return
+	}
+	type unchecked struct {
+		id   int
+		path string
+	}
+	var files []unchecked
+	for rows.Next() {
+		var f unchecked
+		if err := rows.Scan(&f.id, &f.path); err == nil {
+			files = append(files, f)
+		}
+	}
+	rows.Close()
+
+	for _, f := range files {
+		check, reason := contentText, ""
+		info, err := os.Stat(f.path)
+		if err == nil && !info.IsDir() {
+			reason, err = sniffFile(f.path)
+		}
+		if err != nil {
+			fmt.Printf("Не удалось проверить содержимое файла %d: %v\n", f.id, err)
+			continue
+		}
+		if reason != "" {
+			check = contentBinary
+			fmt.Printf("Файл %d помечен как бинарный: %s\n", f.id, reason)
+		}
+		db.Exec(`UPDATE files SET content_check = ?, content_reason = ? WHERE id = ?`, check, reason, f.id)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testPE — начало исполняемого файла Windows: заголовок DOS, заглушка и заголовок PE по смещению 0x80.
+func testPE() []byte {
+	head := make([]byte, 0x200)
+	copy(head, "MZ")
+	binary.LittleEndian.PutUint32(head[0x3C:], 0x80)
+	copy(head[0x4E:], "This program cannot be run in DOS mode.")
+	copy(head[0x80:], "PE\x00\x00")
+	return head
+}
+
+func TestSniffContent(t *testing.T) {
+	// Текст, начинающийся с MZ и достаточно длинный, чтобы в нём было поле e_lfanew
+	mzText := "MZ = 10\nprint(MZ * 2)  # " + strings.Repeat("множитель ", 10) + "\n"
+	outsidePE := testPE()
+	binary.LittleEndian.PutUint32(outsidePE[0x3C:], 0x10000)
+	cases := []struct {
+		name string
+		head []byte
+		want string
+	}{
+		{"пустой файл", nil, ""},
+		{"код на Python", []byte("def f(x):\n\treturn x + 1\n"), ""},
+		{"текст с MZ в начале", []byte(mzText), ""},
+		{"короткий текст MZ", []byte("MZ"), ""},
+		{"текст в CP1251", encodeSingleByte(t, "Привет, мир!\n", &cp1251Table), ""},
+		{"текст в UTF-16 без BOM", encodeUTF16("print('привет')\n", false, false), ""},
+		{"исполняемый файл Windows", testPE(), "обнаружена сигнатура: исполняемый файл Windows"},
+		// Заголовок PE дальше проверяемого начала файла: сигнатуры нет, но выдают нулевые байты
+		{"PE за пределами начала файла", outsidePE, "нулевых байтов"},
+		{"ELF", append([]byte("\x7fELF\x02\x01\x01"), make([]byte, 64)...), "исполняемый файл ELF"},
+		{"PNG", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), "изображение PNG"},
+		{"PDF", []byte("%PDF-1.7\n"), "документ PDF"},
+		{"zip", []byte("PK\x03\x04\x14\x00"), "zip-архив"},
+		{"нулевые байты", append([]byte("abc"), make([]byte, 20)...), "нулевых байтов"},
+		{"управляющие символы", bytes.Repeat([]byte("a\x01\x02\x03"), 10), "управляющих символов"},
+	}
+	for _, c := range cases {
+		got := sniffContent(c.head)
+		if (c.want == "") != (got == "") || !strings.Contains(got, c.want) {
+			t.Errorf("%s: получено %q, ожидалось %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestSniffFile(t *testing.T) {
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "solution.py")
+	os.WriteFile(exe, testPE(), 0644)
+	if reason, err := sniffFile(exe); err != nil || !strings.Contains(reason, "Windows") {
+		t.Errorf("исполняемый файл: %q, %v", reason, err)
+	}
+	text := filepath.Join(dir, "main.py")
+	os.WriteFile(text, []byte("MZ = 1\n"), 0644)
+	if reason, err := sniffFile(text); err != nil || reason != "" {
+		t.Errorf("текст: %q, %v", reason, err)
+	}
+	if _, err := sniffFile(filepath.Join(dir, "missing.py")); err == nil {
+		t.Error("отсутствующий файл: ошибка не возвращена")
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Жизненный цикл работы: uploaded → queued → analyzing → completed, failed или skipped.
+// В очередь работу ставит gateway при запуске анализа, дальше статусом управляет сервис
+// анализа. Повторный анализ снова ставит работу в очередь из любого конечного статуса, а
+// также из queued или analyzing, если статус не менялся дольше statusStaleAfter: значит,
+// анализ прервался и сам работу уже не завершит.
+const (
+	statusUploaded  = "uploaded"
+	statusQueued    = "queued"
+	statusAnalyzing = "analyzing"
+	statusCompleted = "completed"
+	statusFailed    = "failed"
+	statusSkipped   = "skipped"
+)
+
+// statusStaleAfter — через сколько секунд без смены статуса работа в очереди или анализе
+// считается зависшей.
+var statusStaleAfter = envInt64("STATUS_STALE_AFTER", 30*60)
+
+var statuses = []string{statusUploaded, statusQueued, statusAnalyzing, statusCompleted, statusFailed, statusSkipped}
+
+// statusTransitions — из какого статуса в какие можно перейти.
+var statusTransitions = map[string][]string{
+	statusUploaded:  {statusQueued},
+	statusQueued:    {statusAnalyzing, statusFailed},
+	statusAnalyzing: {statusCompleted, statusFailed, statusSkipped},
+	statusCompleted: {statusQueued},
+	statusFailed:    {statusQueued},
+	statusSkipped:   {statusQueued},
+}
+
+// statusTimestamps — колонка, в которую записывается время перехода в статус.
+var statusTimestamps = map[string]string{
+	statusQueued:    "queued_at",
+	statusAnalyzing: "analysis_started_at",
+	statusCompleted: "finished_at",
+	statusFailed:    "finished_at",
+	statusSkipped:   "finished_at",
+}
+
+var errFileNotFound = errors.New("файл не найден")
+
+type transitionError struct {
+	from string
+	to   string
+}
+
+func (e *transitionError) Error() string {
+	return fmt.Sprintf("переход из %s в %s запрещён", e.from, e.to)
+}
+
+// setStatus переводит работу в статус to. Проверка текущего статуса и запись идут одним
+// UPDATE, поэтому два одновременных запуска анализа не пройдут оба.
+func setStatus(id string, to string) error {
+	column, ok := statusTimestamps[to]
+	if !ok {
+		return fmt.Errorf("неизвестный статус %q", to)
+	}
+	var from []string
+	for _, s := range statuses {
+		for _, next := range statusTransitions[s] {
+			if next == to {
+				from = append(from, s)
+			}
+		}
+	}
+	sets := "status = ?, " + column + " = CURRENT_TIMESTAMP, status_updated_at = CURRENT_TIMESTAMP"
+	where := `status IN (?` + strings.Repeat(", ?", len(from)-1) + `)`
+	args := []interface{}{to, id}
+	for _, s := range from {
+		args = append(args, s)
+	}
+	if to == statusQueued {
+		// Повторный анализ: время прошлого запуска больше не относится к работе
+		sets += ", analysis_started_at = NULL, finished_at = NULL"
+		where = `(` + where + ` OR (status IN (?, ?) AND status_updated_at <= datetime('now', ?)))`
+		args = append(args, statusQueued, statusAnalyzing, fmt.Sprintf("-%d seconds", statusStaleAfter))
+	}
+	query := `UPDATE files SET ` + sets + ` WHERE id = ? AND ` + where
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 1 {
+		return nil
+	}
+	var current string
+	err = db.QueryRow(`SELECT status FROM files WHERE id = ?`, id).Scan(&current)
+	if err == sql.ErrNoRows {
+		return errFileNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return &transitionError{from: current, to: to}
+}
+
+// fileStatusHandler — внутренний API для сервиса анализа: POST /internal/files/{id}/status
+// с телом {"status": "queued"}. Запрещённый переход — 409 Conflict.
+func fileStatusHandler(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `Ошибка при парсинге JSON`, http.StatusBadRequest)
+		return
+	}
+	if _, ok := statusTimestamps[req.Status]; !ok {
+		http.Error(w, fmt.Sprintf(`Неизвестный статус %q`, req.Status), http.StatusBadRequest)
+		return
+	}
+	err := setStatus(id, req.Status)
+	var conflict *transitionError
+	switch {
+	case err == errFileNotFound:
+		http.Error(w, `Файл не найден`, http.StatusNotFound)
+		return
+	case errors.As(err, &conflict):
+		http.Error(w, `Недопустимый переход статуса: `+conflict.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		fmt.Println("Ошибка при смене статуса:", err)
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":     id,
+		"status": req.Status,
+	})
+}
+
+// backfillStatuses переводит работы из старого статуса pending: проанализированные —
+// в completed, остальные — в uploaded.
+func backfillStatuses() {
+	query := `
+	UPDATE files SET status = CASE WHEN EXISTS (SELECT 1 FROM reports WHERE reports.file_id = files.id)
+		THEN 'completed' ELSE 'uploaded' END
+	WHERE status = 'pending' OR status IS NULL
+	`
+	_, err := db.Exec(query)
+	if err != nil {
+		// Таблицы отчётов ещё нет — значит, и проанализированных работ нет
+		_, err = db.Exec(`UPDATE files SET status = 'uploaded' WHERE status = 'pending' OR status IS NULL`)
+	}
+	if err != nil {
+		fmt.Println("Ошибка при обновлении статусов:", err)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func insertTestFile(t *testing.T) string {
+	t.Helper()
+	result, err := db.Exec(`INSERT INTO files (student_id, assignment_id, file_path, status) VALUES ('std_1', 'hw1', 'a', 'uploaded')`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _ := result.LastInsertId()
+	return strconv.FormatInt(id, 10)
+}
+
+func fileStatus(t *testing.T, id string) string {
+	t.Helper()
+	var status string
+	if err := db.QueryRow(`SELECT status FROM files WHERE id = ?`, id).Scan(&status); err != nil {
+		t.Fatal(err)
+	}
+	return status
+}
+
+func TestSetStatusTransitions(t *testing.T) {
+	openTestDB(t)
+	// Каждая цепочка начинается с uploaded; ok — разрешён ли последний переход
+	cases := []struct {
+		name  string
+		steps []string
+		ok    bool
+	}{
+		{"анализ завершён", []string{statusQueued, statusAnalyzing, statusCompleted}, true},
+		{"анализ не удался", []string{statusQueued, statusAnalyzing, statusFailed}, true},
+		{"работа пропущена", []string{statusQueued, statusAnalyzing, statusSkipped}, true},
+		{"сбой до начала анализа", []string{statusQueued, statusFailed}, true},
+		{"повторный анализ", []string{statusQueued, statusAnalyzing, statusCompleted, statusQueued}, true},
+		{"анализ без очереди", []string{statusAnalyzing}, false},
+		{"завершение без анализа", []string{statusQueued, statusCompleted}, false},
+		{"свежая работа в очереди", []string{statusQueued, statusQueued}, false},
+		{"свежий анализ", []string{statusQueued, statusAnalyzing, statusQueued}, false},
+		{"завершённая снова завершается", []string{statusQueued, statusAnalyzing, statusCompleted, statusFailed}, false},
+	}
+	for _, c := range cases {
+		id := insertTestFile(t)
+		for i, to := range c.steps {
+			err := setStatus(id, to)
+			if i < len(c.steps)-1 {
+				if err != nil {
+					t.Fatalf("%s: шаг %s: %v", c.name, to, err)
+				}
+				continue
+			}
+			var conflict *transitionError
+			switch {
+			case c.ok && err != nil:
+				t.Errorf("%s: переход в %s отклонён: %v", c.name, to, err)
+			case !c.ok && !errors.As(err, &conflict):
+				t.Errorf("%s: переход в %s: ошибка %v, ожидался запрет", c.name, to, err)
+			case c.ok && fileStatus(t, id) != to:
+				t.Errorf("%s: статус %s, ожидался %s", c.name, fileStatus(t, id), to)
+			}
+		}
+	}
+
+	if err := setStatus("999", statusQueued); err != errFileNotFound {
+		t.Errorf("несуществующая работа: %v", err)
+	}
+	if err := setStatus(insertTestFile(t), statusUploaded); err == nil {
+		t.Error("переход в uploaded разрешён")
+	}
+}
+
+func TestSetStatusStaleRequeue(t *testing.T) {
+	openTestDB(t)
+	for _, stuck := range []string{statusQueued, statusAnalyzing} {
+		id := insertTestFile(t)
+		setStatus(id, statusQueued)
+		if stuck == statusAnalyzing {
+			setStatus(id, statusAnalyzing)
+		}
+		// Анализ прервался совсем недавно: повторная постановка в очередь запрещена
+		if err := setStatus(id, statusQueued); err == nil {
+			t.Fatalf("%s: свежая работа снова поставлена в очередь", stuck)
+		}
+		db.Exec(`UPDATE files SET status_updated_at = datetime('now', ?) WHERE id = ?`,
+			"-"+strconv.FormatInt(statusStaleAfter+60, 10)+" seconds", id)
+		if err := setStatus(id, statusQueued); err != nil {
+			t.Fatalf("%s: зависшая работа не поставлена в очередь: %v", stuck, err)
+		}
+		var started, finished interface{}
+		db.QueryRow(`SELECT analysis_started_at, finished_at FROM files WHERE id = ?`, id).Scan(&started, &finished)
+		if started != nil || finished != nil {
+			t.Errorf("%s: время прошлого запуска не сброшено: %v, %v", stuck, started, finished)
+		}
+		// После повторной постановки работа снова считается свежей
+		if err := setStatus(id, statusQueued); err == nil {
+			t.Errorf("%s: работа поставлена в очередь дважды", stuck)
+		}
+	}
+}
+
+func TestFileStatusHandler(t *testing.T) {
+	openTestDB(t)
+	id := insertTestFile(t)
+	cases := []struct {
+		name   string
+		method string
+		id     string
+		body   string
+		code   int
+	}{
+		{"в очередь", http.MethodPost, id, `{"status": "queued"}`, http.StatusOK},
+		{"повторно в очередь", http.MethodPost, id, `{"status": "queued"}`, http.StatusConflict},
+		{"неизвестный статус", http.MethodPost, id, `{"status": "done"}`, http.StatusBadRequest},
+		{"некорректный JSON", http.MethodPost, id, `{`, http.StatusBadRequest},
+		{"нет работы", http.MethodPost, "999", `{"status": "queued"}`, http.StatusNotFound},
+		{"GET", http.MethodGet, id, ``, http.StatusMethodNotAllowed},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		fileStatusHandler(w, httptest.NewRequest(c.method, "/internal/files/"+c.id+"/status", strings.NewReader(c.body)), c.id)
+		if w.Code != c.code {
+			t.Errorf("%s: код %d, ожидался %d: %s", c.name, w.Code, c.code, w.Body)
+		}
+	}
+}
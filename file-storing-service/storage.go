@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Storage — хранилище блобов. Ключ — путь с прямыми слешами вида blobs/ab/<sha256>.py;
+// проект из архива лежит под префиксом blobs/ab/<sha256 архива>/. Бэкенд выбирается
+// переменной STORAGE_BACKEND: local (по умолчанию) или s3.
+type Storage interface {
+	// Put сохраняет объект целиком: читатели не увидят его недописанным.
+	Put(key string, r io.ReadSeeker) error
+	// Open открывает объект на чтение; если его нет — errObjectNotFound.
+	Open(key string) (io.ReadCloser, error)
+	Exists(key string) (bool, error)
+	// List возвращает ключи всех объектов с префиксом prefix в порядке сортировки.
+	List(prefix string) ([]string, error)
+}
+
+var errObjectNotFound = errors.New("объект не найден в хранилище")
+
+var storage Storage
+
+func openStorage() Storage {
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", "local":
+		dir := os.Getenv("STORAGE_DIR")
+		if dir == "" {
+			dir = uploadsDir()
+		}
+		fmt.Println("Хранилище блобов: локальный каталог", dir)
+		return &localStorage{root: dir}
+	case "s3":
+		s, err := newS3Storage()
+		if err != nil {
+			panic("Ошибка настройки S3: " + err.Error())
+		}
+		fmt.Printf("Хранилище блобов: S3 %s, бакет %s\n", s.endpoint, s.bucket)
+		return s
+	default:
+		panic("Неизвестный STORAGE_BACKEND: " + backend)
+	}
+}
+
+func uploadsDir() string {
+	dir := "/app/uploads"
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		dir = "./uploads"
+	}
+	return dir
+}
+
+// readObject читает объект целиком.
+func readObject(key string) ([]byte, error) {
+	rc, err := storage.Open(key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// localStorage хранит объекты файлами в каталоге root. Абсолютный путь вместо ключа
+// читается как есть: так записаны работы, загруженные до появления хранилища.
+type localStorage struct {
+	root string
+}
+
+func (s *localStorage) path(key string) string {
+	if filepath.IsAbs(key) {
+		return key
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key))
+}
+
+func (s *localStorage) Put(key string, r io.ReadSeeker) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tmp, r)
+	tmp.Close()
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *localStorage) Open(key string) (io.ReadCloser, error) {
+	path := s.path(key)
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) || (err == nil && info.IsDir()) {
+		return nil, errObjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+func (s *localStorage) Exists(key string) (bool, error) {
+	info, err := os.Stat(s.path(key))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !info.IsDir(), nil
+}
+
+func (s *localStorage) List(prefix string) ([]string, error) {
+	dir := s.path(strings.TrimSuffix(prefix, "/"))
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, nil
+	}
+	var keys []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || strings.Contains(d.Name(), ".tmp-") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, strings.TrimSuffix(prefix, "/")+"/"+filepath.ToSlash(rel))
+		return nil
+	})
+	sort.Strings(keys)
+	return keys, err
+}
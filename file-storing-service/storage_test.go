@@ -0,0 +1,55 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorage(t *testing.T) {
+	root := t.TempDir()
+	s := &localStorage{root: root}
+	objects := map[string]string{
+		"blobs/ab/abc/main.py":   "print(1)\n",
+		"blobs/ab/abc/lib/a.py":  "x = 1\n",
+		"blobs/ab/abc/empty.txt": "",
+	}
+	for key, content := range objects {
+		if err := s.Put(key, strings.NewReader(content)); err != nil {
+			t.Fatalf("Put(%s): %v", key, err)
+		}
+	}
+	for key, content := range objects {
+		rc, err := s.Open(key)
+		if err != nil {
+			t.Fatalf("Open(%s): %v", key, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		if string(data) != content {
+			t.Errorf("Open(%s) = %q, ожидалось %q", key, data, content)
+		}
+	}
+	// Недописанный временный файл не попадает в список
+	os.WriteFile(filepath.Join(root, "blobs/ab/abc/main.py.tmp-123"), []byte("pri"), 0644)
+	keys, err := s.List("blobs/ab/abc/")
+	want := []string{"blobs/ab/abc/empty.txt", "blobs/ab/abc/lib/a.py", "blobs/ab/abc/main.py"}
+	if err != nil || strings.Join(keys, ",") != strings.Join(want, ",") {
+		t.Fatalf("List = %v, %v; ожидалось %v", keys, err, want)
+	}
+	if keys, err := s.List("blobs/zz/"); len(keys) != 0 || err != nil {
+		t.Fatalf("List несуществующего префикса = %v, %v", keys, err)
+	}
+
+	if _, err := s.Open("blobs/ab/abc"); err != errObjectNotFound {
+		t.Errorf("Open каталога: %v", err)
+	}
+	if ok, _ := s.Exists("blobs/ab/abc"); ok {
+		t.Error("каталог считается объектом")
+	}
+	if _, err := s.Open("blobs/ab/abc/missing.py"); err != errObjectNotFound {
+		t.Errorf("Open отсутствующего объекта: %v", err)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"shared/formats"
+)
+
+// Лимит размера работы: UPLOAD_MAX_SIZE для всех заданий и UPLOAD_MAX_SIZE_BY_ASSIGNMENT
+// для отдельных, например "task-001=10485760,task-007=104857600" (в байтах).
+var (
+	uploadMaxSize      = envInt64("UPLOAD_MAX_SIZE", 50<<20)
+	assignmentMaxSizes = parseSizeLimits(os.Getenv("UPLOAD_MAX_SIZE_BY_ASSIGNMENT"))
+)
+
+// maxFieldSize — предел для текстовых полей формы; formOverhead — запас на поля и
+// границы частей сверх размера файла.
+const (
+	maxFieldSize = 64 << 10
+	formOverhead = 1 << 20
+)
+
+func parseSizeLimits(s string) map[string]int64 {
+	limits := map[string]int64{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		assignmentID, value, _ := strings.Cut(entry, "=")
+		size, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil || size <= 0 || strings.TrimSpace(assignmentID) == "" {
+			panic("Некорректный UPLOAD_MAX_SIZE_BY_ASSIGNMENT: " + entry)
+		}
+		limits[strings.TrimSpace(assignmentID)] = size
+	}
+	return limits
+}
+
+// uploadLimit — сколько байт можно загрузить в задание: max_size задания, если он задан,
+// иначе лимит из переменных окружения.
+func uploadLimit(assignmentID string) int64 {
+	var maxSize int64
+	db.QueryRow(`SELECT max_size FROM assignments WHERE id = ?`, assignmentID).Scan(&maxSize)
+	if maxSize > 0 {
+		return maxSize
+	}
+	if size, ok := assignmentMaxSizes[assignmentID]; ok {
+		return size
+	}
+	return uploadMaxSize
+}
+
+// largestUploadLimit — лимит, пока задание ещё неизвестно (поле пришло после файла).
+func largestUploadLimit() int64 {
+	largest := uploadMaxSize
+	var maxSize int64
+	db.QueryRow(`SELECT COALESCE(MAX(max_size), 0) FROM assignments`).Scan(&maxSize)
+	largest = max(largest, maxSize)
+	for _, size := range assignmentMaxSizes {
+		if size > largest {
+			largest = size
+		}
+	}
+	return largest
+}
+
+func tooLargeError(limit int64) *uploadError {
+	return &uploadError{code: http.StatusRequestEntityTooLarge, message: fmt.Sprintf(`Файл больше %d байт`, limit)}
+}
+
+// receiveUpload читает форму /upload потоком, не собирая её в памяти: файл пишется во
+// временный файл и одновременно хэшируется, а лимит задания проверяется по ходу чтения.
+// Поэтому student_id и assignment_id стоит передавать в форме до файла: иначе во время
+// чтения действует наибольший из лимитов, а лимит задания проверяется после.
+// cleanup удаляет временный файл и вызывается и при ошибке.
+func receiveUpload(w http.ResponseWriter, r *http.Request) (u upload, cleanup func(), uerr *uploadError) {
+	var spool *os.File
+	cleanup = func() {
+		if spool != nil {
+			spool.Close()
+			os.Remove(spool.Name())
+		}
+	}
+	u.submittedAt = time.Now()
+	r.Body = http.MaxBytesReader(w, r.Body, largestUploadLimit()+formOverhead)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return u, cleanup, &uploadError{code: http.StatusBadRequest, message: `Ошибка при парсинге формы`}
+	}
+	var tooLarge *http.MaxBytesError
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if errors.As(err, &tooLarge) {
+			return u, cleanup, tooLargeError(uploadLimit(u.assignmentID))
+		}
+		if err != nil {
+			return u, cleanup, &uploadError{code: http.StatusBadRequest, message: `Ошибка при парсинге формы`}
+		}
+		switch name := part.FormName(); name {
+		case "student_id", "assignment_id", "comment":
+			value, err := io.ReadAll(io.LimitReader(part, maxFieldSize+1))
+			if err != nil || len(value) > maxFieldSize {
+				return u, cleanup, &uploadError{code: http.StatusBadRequest, message: fmt.Sprintf(`Поле %s слишком длинное`, name)}
+			}
+			switch name {
+			case "student_id":
+				u.studentID = string(value)
+			case "assignment_id":
+				u.assignmentID = string(value)
+			case "comment":
+				u.comment = strings.TrimSpace(string(value))
+			}
+		case "file":
+			if spool != nil {
+				return u, cleanup, &uploadError{code: http.StatusBadRequest, message: `Можно загрузить только один файл`}
+			}
+			u.filename = part.FileName()
+			// Неподдерживаемый формат отклоняется до чтения файла
+			if formats.For(u.filename) == nil {
+				return u, cleanup, u.validate()
+			}
+			limit := largestUploadLimit()
+			if u.assignmentID != "" {
+				limit = uploadLimit(u.assignmentID)
+			}
+			// Если студент и задание уже известны, задание и квоты тоже проверяются до чтения,
+			// а квоты — и во время него
+			var usage *quotaUsage
+			if u.studentID != "" && u.assignmentID != "" {
+				if uerr := u.validate(); uerr != nil {
+					return u, cleanup, uerr
+				}
+				loaded, err := loadQuotaUsage(db, u.studentID, u.assignmentID, "")
+				if err != nil {
+					fmt.Println("Ошибка при подсчёте квот:", err)
+					return u, cleanup, &uploadError{code: http.StatusInternalServerError, message: `Ошибка при запросе к БД`}
+				}
+				if uerr := loaded.check(u.assignmentID, 0); uerr != nil {
+					return u, cleanup, uerr
+				}
+				usage = &loaded
+				limit = min(limit, usage.remainingBytes())
+			}
+			spool, err = os.CreateTemp("", "upload-*.part")
+			if err != nil {
+				fmt.Println("Ошибка при создании временного файла:", err)
+				return u, cleanup, &uploadError{code: http.StatusInternalServerError, message: `Ошибка при сохранении файла`}
+			}
+			h := sha256.New()
+			u.size, err = io.Copy(io.MultiWriter(spool, h), io.LimitReader(part, limit+1))
+			if u.size > limit || errors.As(err, &tooLarge) {
+				fmt.Printf("Загрузка %s прервана: больше %d байт\n", u.filename, limit)
+				if usage != nil {
+					if uerr := usage.check(u.assignmentID, u.size); uerr != nil {
+						return u, cleanup, uerr
+					}
+				}
+				return u, cleanup, tooLargeError(limit)
+			}
+			if err != nil {
+				fmt.Println("Ошибка при чтении файла:", err)
+				return u, cleanup, &uploadError{code: http.StatusBadRequest, message: `Ошибка при чтении файла`}
+			}
+			u.hash = hex.EncodeToString(h.Sum(nil))
+		default:
+			io.Copy(io.Discard, part)
+		}
+	}
+	if spool == nil {
+		return u, cleanup, &uploadError{code: http.StatusBadRequest, message: `{"error": "Файл не найден в запросе"}`}
+	}
+	if limit := uploadLimit(u.assignmentID); u.size > limit {
+		return u, cleanup, tooLargeError(limit)
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return u, cleanup, &uploadError{code: http.StatusInternalServerError, message: `Ошибка при чтении файла`}
+	}
+	u.file = spool
+	return u, cleanup, nil
+}
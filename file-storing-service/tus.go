@@ -0,0 +1,505 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"shared/pagination"
+)
+
+// Возобновляемая загрузка по протоколу tus 1.0.0 (https://tus.io/protocols/resumable-upload),
+// расширения creation, termination и expiration:
+//
+//	POST   /uploads       — создать загрузку: Upload-Length и Upload-Metadata (filename, student_id,
+//	                        assignment_id, comment в base64), в ответ Location
+//	HEAD   /uploads/{id}  — сколько байт уже получено (Upload-Offset)
+//	PATCH  /uploads/{id}  — дописать часть с позиции Upload-Offset
+//	DELETE /uploads/{id}  — отменить загрузку
+//
+// Части копятся в файле в TUS_DIR. Когда получен последний байт, файл проходит тот же путь,
+// что и работа из /upload, а ID работы возвращается в заголовке Upload-File-Id. Загрузка,
+// в которую дольше TUS_EXPIRE_AFTER не приходило частей, истекает (заголовок Upload-Expires)
+// и удаляется фоновой очисткой.
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,termination,expiration"
+)
+
+var tusMaxSize = envInt64("TUS_MAX_SIZE", 100<<20)
+
+var tusExpireAfter = time.Duration(envInt64("TUS_EXPIRE_AFTER", 24*60*60)) * time.Second
+
+var errTusOverflow = errors.New("данные за пределами Upload-Length")
+
+// tusUpload — незавершённая (или уже зарегистрированная, если FileID задан) загрузка.
+type tusUpload struct {
+	ID           string
+	Length       int64
+	Filename     string
+	StudentID    string
+	AssignmentID string
+	Comment      string
+	FileID       sql.NullInt64
+	// CreatedAt — время создания загрузки: по нему считается, сдана ли работа в срок.
+	CreatedAt time.Time
+}
+
+func tusDir() string {
+	if dir := os.Getenv("TUS_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(uploadsDir(), ".tus")
+}
+
+func (u tusUpload) partPath() string {
+	return filepath.Join(tusDir(), u.ID)
+}
+
+// offset — сколько байт уже получено: у завершённой загрузки файла частей больше нет.
+func (u tusUpload) offset() (int64, error) {
+	if u.FileID.Valid {
+		return u.Length, nil
+	}
+	info, err := os.Stat(u.partPath())
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// expiresAt — когда незавершённая загрузка истечёт: срок отсчитывается от последней
+// полученной части, а пока частей не было — от создания загрузки.
+func (u tusUpload) expiresAt() time.Time {
+	last := u.CreatedAt
+	if info, err := os.Stat(u.partPath()); err == nil && info.ModTime().After(last) {
+		last = info.ModTime()
+	}
+	return last.Add(tusExpireAfter)
+}
+
+// expired — истёк ли срок загрузки. Завершённая загрузка нужна только клиенту, который
+// переспрашивает Upload-File-Id, и хранится тот же срок от создания.
+func (u tusUpload) expired(now time.Time) bool {
+	if u.FileID.Valid {
+		return now.After(u.CreatedAt.Add(tusExpireAfter))
+	}
+	return now.After(u.expiresAt())
+}
+
+// setTusExpires сообщает клиенту срок незавершённой загрузки.
+func setTusExpires(w http.ResponseWriter, u tusUpload) {
+	if !u.FileID.Valid {
+		w.Header().Set("Upload-Expires", u.expiresAt().UTC().Format(http.TimeFormat))
+	}
+}
+
+func loadTusUpload(id string) (tusUpload, error) {
+	query := `
+	SELECT id, upload_length, filename, student_id, assignment_id, comment, file_id, COALESCE(created_at, '')
+	FROM tus_uploads WHERE id = ?
+	`
+	var u tusUpload
+	var createdAt string
+	err := db.QueryRow(query, id).Scan(&u.ID, &u.Length, &u.Filename, &u.StudentID, &u.AssignmentID, &u.Comment, &u.FileID, &createdAt)
+	if err != nil {
+		return u, err
+	}
+	if t, _, err := pagination.ParseTime(createdAt); err == nil {
+		u.CreatedAt = t
+	} else {
+		u.CreatedAt = time.Now()
+	}
+	return u, nil
+}
+
+func deleteTusUpload(u tusUpload) {
+	os.Remove(u.partPath())
+	db.Exec(`DELETE FROM tus_uploads WHERE id = ?`, u.ID)
+}
+
+// tusLocks не даёт двум запросам одновременно дописывать одну загрузку. Блокировка живёт в
+// памяти процесса, поэтому сервис хранения запускается в одном экземпляре.
+var tusLocks = struct {
+	sync.Mutex
+	busy map[string]bool
+}{busy: map[string]bool{}}
+
+func lockTusUpload(id string) bool {
+	tusLocks.Lock()
+	defer tusLocks.Unlock()
+	if tusLocks.busy[id] {
+		return false
+	}
+	tusLocks.busy[id] = true
+	return true
+}
+
+func unlockTusUpload(id string) {
+	tusLocks.Lock()
+	defer tusLocks.Unlock()
+	delete(tusLocks.busy, id)
+}
+
+// expireTusUploads удаляет просроченные загрузки вместе с файлами частей, а также файлы
+// частей старше срока, для которых нет записи (сервис остановился посреди создания загрузки).
+func expireTusUploads() {
+	rows, err := db.Query(`SELECT id FROM tus_uploads`)
+	if err != nil {
+		fmt.Println("Ошибка при поиске просроченных загрузок:", err)
+		return
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	now := time.Now()
+	known := map[string]bool{}
+	removed := 0
+	for _, id := range ids {
+		known[id] = true
+		u, err := loadTusUpload(id)
+		if err != nil || !u.expired(now) || !lockTusUpload(id) {
+			continue
+		}
+		deleteTusUpload(u)
+		unlockTusUpload(id)
+		removed++
+	}
+	entries, _ := os.ReadDir(tusDir())
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || known[entry.Name()] || !info.Mode().IsRegular() || now.Sub(info.ModTime()) < tusExpireAfter {
+			continue
+		}
+		os.Remove(filepath.Join(tusDir(), entry.Name()))
+		removed++
+	}
+	if removed > 0 {
+		fmt.Println("Удалено просроченных загрузок tus:", removed)
+	}
+}
+
+// runTusExpiration периодически удаляет просроченные загрузки.
+func runTusExpiration() {
+	interval := min(tusExpireAfter, 10*time.Minute)
+	for {
+		expireTusUploads()
+		time.Sleep(interval)
+	}
+}
+
+func tusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	if override := r.Header.Get("X-HTTP-Method-Override"); override != "" && r.Method == http.MethodPost {
+		r.Method = override
+	}
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Tus-Version", tusVersion)
+		w.Header().Set("Tus-Extension", tusExtensions)
+		w.Header().Set("Tus-Max-Size", strconv.FormatInt(tusMaxSize, 10))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		http.Error(w, `Поддерживается только tus `+tusVersion, http.StatusPreconditionFailed)
+		return
+	}
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/uploads"), "/")
+	if id == "" {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		tusCreateHandler(w, r)
+		return
+	}
+	u, err := loadTusUpload(id)
+	if err == sql.ErrNoRows {
+		http.Error(w, `Загрузка не найдена`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
+	if !u.FileID.Valid && u.expired(time.Now()) {
+		http.Error(w, `Срок загрузки истёк`, http.StatusGone)
+		return
+	}
+	switch r.Method {
+	case http.MethodHead:
+		tusHeadHandler(w, u)
+	case http.MethodPatch:
+		tusPatchHandler(w, r, u)
+	case http.MethodDelete:
+		if !lockTusUpload(u.ID) {
+			http.Error(w, `Загрузка занята другим запросом`, http.StatusConflict)
+			return
+		}
+		defer unlockTusUpload(u.ID)
+		deleteTusUpload(u)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Only HEAD, PATCH and DELETE methods are allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func tusCreateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Upload-Defer-Length") != "" {
+		http.Error(w, `Upload-Defer-Length не поддерживается`, http.StatusBadRequest)
+		return
+	}
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		http.Error(w, `Upload-Length должен быть положительным числом`, http.StatusBadRequest)
+		return
+	}
+	metadata, err := parseTusMetadata(r.Header.Get("Upload-Metadata"))
+	if err != nil {
+		http.Error(w, `Некорректный Upload-Metadata`, http.StatusBadRequest)
+		return
+	}
+	if limit := min(tusMaxSize, uploadLimit(metadata["assignment_id"])); length > limit {
+		tooLargeError(limit).write(w)
+		return
+	}
+	u := tusUpload{
+		ID:           newTusID(),
+		Length:       length,
+		Filename:     metadata["filename"],
+		StudentID:    metadata["student_id"],
+		AssignmentID: metadata["assignment_id"],
+		Comment:      strings.TrimSpace(metadata["comment"]),
+		CreatedAt:    time.Now(),
+	}
+	// Всё, что можно проверить без содержимого, проверяется сразу, а не после загрузки
+	pending := upload{filename: u.Filename, studentID: u.StudentID, assignmentID: u.AssignmentID, comment: u.Comment, submittedAt: u.CreatedAt}
+	if uerr := pending.validate(); uerr != nil {
+		uerr.write(w)
+		return
+	}
+	// Загрузка резервирует квоту, поэтому проверка и запись идут в одной транзакции
+	tx, err := db.Begin()
+	if err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	if uerr := checkQuota(tx, u.StudentID, u.AssignmentID, "", u.Length); uerr != nil {
+		uerr.write(w)
+		return
+	}
+	if err := os.MkdirAll(tusDir(), 0755); err != nil {
+		fmt.Println("Ошибка при создании каталога загрузок:", err)
+		http.Error(w, `Ошибка при создании загрузки`, http.StatusInternalServerError)
+		return
+	}
+	if err := os.WriteFile(u.partPath(), nil, 0644); err != nil {
+		fmt.Println("Ошибка при создании файла загрузки:", err)
+		http.Error(w, `Ошибка при создании загрузки`, http.StatusInternalServerError)
+		return
+	}
+	query := `
+	INSERT INTO tus_uploads (id, upload_length, filename, student_id, assignment_id, comment)
+	VALUES (?, ?, ?, ?, ?, ?)
+	`
+	_, err = tx.Exec(query, u.ID, u.Length, u.Filename, u.StudentID, u.AssignmentID, u.Comment)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		os.Remove(u.partPath())
+		http.Error(w, `Ошибка при сохранении данных в БД`, http.StatusInternalServerError)
+		return
+	}
+	fmt.Printf("Создана загрузка %s: %s, %d байт\n", u.ID, u.Filename, u.Length)
+	w.Header().Set("Location", "/uploads/"+u.ID)
+	setTusExpires(w, u)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func tusHeadHandler(w http.ResponseWriter, u tusUpload) {
+	offset, err := u.offset()
+	if err != nil {
+		http.Error(w, `Ошибка чтения загрузки`, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(u.Length, 10))
+	if u.FileID.Valid {
+		w.Header().Set("Upload-File-Id", strconv.FormatInt(u.FileID.Int64, 10))
+	}
+	setTusExpires(w, u)
+	w.WriteHeader(http.StatusOK)
+}
+
+func tusPatchHandler(w http.ResponseWriter, r *http.Request, u tusUpload) {
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		http.Error(w, `Content-Type должен быть application/offset+octet-stream`, http.StatusUnsupportedMediaType)
+		return
+	}
+	clientOffset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || clientOffset < 0 {
+		http.Error(w, `Upload-Offset должен быть неотрицательным числом`, http.StatusBadRequest)
+		return
+	}
+	if !lockTusUpload(u.ID) {
+		http.Error(w, `Загрузка занята другим запросом`, http.StatusConflict)
+		return
+	}
+	defer unlockTusUpload(u.ID)
+	// Пока ждали блокировку, загрузку могли дописать или отменить
+	u, err = loadTusUpload(u.ID)
+	if err == sql.ErrNoRows {
+		http.Error(w, `Загрузка не найдена`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
+	offset, err := u.offset()
+	if err != nil {
+		http.Error(w, `Ошибка чтения загрузки`, http.StatusInternalServerError)
+		return
+	}
+	if clientOffset != offset {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+		http.Error(w, fmt.Sprintf(`Upload-Offset не совпадает: получено %d байт`, offset), http.StatusConflict)
+		return
+	}
+	// Если длина тела известна, лишнее отклоняется до записи; у chunked-запроса лишние
+	// байты обнаружит appendTusPart, дописав только то, что помещается в Upload-Length
+	if r.ContentLength > u.Length-offset {
+		w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+		http.Error(w, `Тело запроса длиннее оставшейся части файла`, http.StatusBadRequest)
+		return
+	}
+	if offset < u.Length {
+		offset, err = appendTusPart(u, offset, r.Body)
+		if errors.Is(err, errTusOverflow) {
+			w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+			http.Error(w, `Тело запроса длиннее оставшейся части файла`, http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			// Дописанное до обрыва остаётся: клиент продолжит с нового Upload-Offset
+			fmt.Printf("Загрузка %s прервана на %d байт: %v\n", u.ID, offset, err)
+			w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+			http.Error(w, `Ошибка при получении части файла`, http.StatusInternalServerError)
+			return
+		}
+	}
+	if offset == u.Length && !u.FileID.Valid {
+		fileID, uerr := finishTusUpload(u)
+		if uerr != nil {
+			uerr.write(w)
+			return
+		}
+		u.FileID = sql.NullInt64{Int64: fileID, Valid: true}
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	if u.FileID.Valid {
+		w.Header().Set("Upload-File-Id", strconv.FormatInt(u.FileID.Int64, 10))
+	}
+	setTusExpires(w, u)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// appendTusPart дописывает тело запроса в файл частей и возвращает новую позицию.
+// Байты сверх Upload-Length не принимаются.
+func appendTusPart(u tusUpload, offset int64, body io.Reader) (int64, error) {
+	f, err := os.OpenFile(u.partPath(), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return offset, err
+	}
+	n, err := io.Copy(f, io.LimitReader(body, u.Length-offset))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	offset += n
+	if err != nil {
+		return offset, err
+	}
+	if offset == u.Length {
+		if extra, _ := body.Read(make([]byte, 1)); extra > 0 {
+			return offset, errTusOverflow
+		}
+	}
+	return offset, nil
+}
+
+// finishTusUpload регистрирует полностью полученный файл как работу. Если работу отклонили
+// (формат, лимиты архива), загрузка удаляется; при внутренней ошибке остаётся, и регистрацию
+// можно повторить пустым PATCH с Upload-Offset, равным Upload-Length.
+func finishTusUpload(u tusUpload) (int64, *uploadError) {
+	f, err := os.Open(u.partPath())
+	if err != nil {
+		return 0, &uploadError{code: http.StatusInternalServerError, message: `Ошибка чтения загрузки`}
+	}
+	defer f.Close()
+	response, uerr := storeUpload(upload{
+		file:         f,
+		filename:     u.Filename,
+		size:         u.Length,
+		studentID:    u.StudentID,
+		assignmentID: u.AssignmentID,
+		comment:      u.Comment,
+		submittedAt:  u.CreatedAt,
+		tusID:        u.ID,
+	})
+	if uerr != nil {
+		if uerr.code < http.StatusInternalServerError {
+			deleteTusUpload(u)
+		}
+		return 0, uerr
+	}
+	fileID := response["file_id"].(int64)
+	os.Remove(u.partPath())
+	fmt.Printf("Загрузка %s завершена, File ID %d\n", u.ID, fileID)
+	return fileID, nil
+}
+
+// parseTusMetadata разбирает Upload-Metadata: пары «ключ значение-в-base64» через запятую.
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("пустой ключ")
+		}
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, err
+		}
+		metadata[key] = string(decoded)
+	}
+	return metadata, nil
+}
+
+func newTusID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
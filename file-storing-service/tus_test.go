@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// openTestStorage подключает пакет к новой БД с заданием hw1 и локальному хранилищу
+// во временных каталогах.
+func openTestStorage(t *testing.T) {
+	t.Helper()
+	openTestDB(t)
+	t.Setenv("TUS_DIR", t.TempDir())
+	saved := storage
+	storage = &localStorage{root: t.TempDir()}
+	t.Cleanup(func() { storage = saved })
+	if _, err := db.Exec(`INSERT INTO assignments (id, title) VALUES ('hw1', 'Задание 1')`); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func tusRequest(method string, path string, body string, headers map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Tus-Resumable", tusVersion)
+	if method == http.MethodPatch {
+		r.Header.Set("Content-Type", "application/offset+octet-stream")
+	}
+	for name, value := range headers {
+		r.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	tusHandler(w, r)
+	return w
+}
+
+func tusMetadata(values map[string]string) string {
+	var pairs []string
+	for key, value := range values {
+		pairs = append(pairs, key+" "+base64.StdEncoding.EncodeToString([]byte(value)))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// createTusUpload создаёт загрузку main.py от студента std_1 и возвращает её путь.
+func createTusUpload(t *testing.T, length int) string {
+	t.Helper()
+	w := tusRequest(http.MethodPost, "/uploads", "", map[string]string{
+		"Upload-Length":   strconv.Itoa(length),
+		"Upload-Metadata": tusMetadata(map[string]string{"filename": "main.py", "student_id": "std_1", "assignment_id": "hw1"}),
+	})
+	if w.Code != http.StatusCreated || !strings.HasPrefix(w.Header().Get("Location"), "/uploads/") {
+		t.Fatalf("создание загрузки: код %d, Location %q, %s", w.Code, w.Header().Get("Location"), w.Body)
+	}
+	if w.Header().Get("Upload-Expires") == "" {
+		t.Error("нет заголовка Upload-Expires")
+	}
+	return w.Header().Get("Location")
+}
+
+func TestTusUpload(t *testing.T) {
+	openTestStorage(t)
+	content := "print('hello')\nprint('world')\n"
+	location := createTusUpload(t, len(content))
+
+	head := func(wantOffset int) *httptest.ResponseRecorder {
+		t.Helper()
+		w := tusRequest(http.MethodHead, location, "", nil)
+		if w.Code != http.StatusOK || w.Header().Get("Upload-Offset") != strconv.Itoa(wantOffset) {
+			t.Fatalf("HEAD: код %d, Upload-Offset %q, ожидалось %d", w.Code, w.Header().Get("Upload-Offset"), wantOffset)
+		}
+		return w
+	}
+	head(0)
+
+	w := tusRequest(http.MethodPatch, location, content[:10], map[string]string{"Upload-Offset": "0"})
+	if w.Code != http.StatusNoContent || w.Header().Get("Upload-Offset") != "10" {
+		t.Fatalf("первая часть: код %d, Upload-Offset %q, %s", w.Code, w.Header().Get("Upload-Offset"), w.Body)
+	}
+	head(10)
+
+	// Повтор уже принятой части отклоняется, клиенту сообщается текущая позиция
+	w = tusRequest(http.MethodPatch, location, content[:10], map[string]string{"Upload-Offset": "0"})
+	if w.Code != http.StatusConflict || w.Header().Get("Upload-Offset") != "10" {
+		t.Fatalf("несовпадающий Upload-Offset: код %d, Upload-Offset %q", w.Code, w.Header().Get("Upload-Offset"))
+	}
+
+	w = tusRequest(http.MethodPatch, location, content[10:], map[string]string{"Upload-Offset": "10"})
+	if w.Code != http.StatusNoContent || w.Header().Get("Upload-File-Id") == "" {
+		t.Fatalf("последняя часть: код %d, Upload-File-Id %q, %s", w.Code, w.Header().Get("Upload-File-Id"), w.Body)
+	}
+	fileID := w.Header().Get("Upload-File-Id")
+	var size int64
+	var status string
+	if err := db.QueryRow(`SELECT size, status FROM files WHERE id = ?`, fileID).Scan(&size, &status); err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(len(content)) || status != "uploaded" {
+		t.Errorf("работа %s: размер %d, статус %s", fileID, size, status)
+	}
+	if w := head(len(content)); w.Header().Get("Upload-File-Id") != fileID {
+		t.Errorf("HEAD завершённой загрузки: Upload-File-Id %q, ожидалось %s", w.Header().Get("Upload-File-Id"), fileID)
+	}
+
+	if w := tusRequest(http.MethodDelete, location, "", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE: код %d", w.Code)
+	}
+	if w := tusRequest(http.MethodHead, location, "", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("HEAD удалённой загрузки: код %d", w.Code)
+	}
+}
+
+func TestTusPatchOverflow(t *testing.T) {
+	openTestStorage(t)
+	location := createTusUpload(t, 10)
+	u, err := loadTusUpload(strings.TrimPrefix(location, "/uploads/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Тело длиннее Upload-Length отклоняется до записи: в файле частей ничего не появляется
+	w := tusRequest(http.MethodPatch, location, "x = 1\nprint(x)\n", map[string]string{"Upload-Offset": "0"})
+	if w.Code != http.StatusBadRequest || w.Header().Get("Upload-Offset") != "0" {
+		t.Fatalf("переполнение: код %d, Upload-Offset %q", w.Code, w.Header().Get("Upload-Offset"))
+	}
+	if info, err := os.Stat(u.partPath()); err != nil || info.Size() != 0 {
+		t.Fatalf("файл частей после отклонённого PATCH: %v, %v", info, err)
+	}
+
+	w = tusRequest(http.MethodPatch, location, "x = 1\n", map[string]string{"Upload-Offset": "0"})
+	if w.Code != http.StatusNoContent || w.Header().Get("Upload-Offset") != "6" {
+		t.Fatalf("часть: код %d, Upload-Offset %q", w.Code, w.Header().Get("Upload-Offset"))
+	}
+	w = tusRequest(http.MethodPatch, location, "print(x)\n", map[string]string{"Upload-Offset": "6"})
+	if w.Code != http.StatusBadRequest || w.Header().Get("Upload-Offset") != "6" {
+		t.Fatalf("переполнение второй частью: код %d, Upload-Offset %q", w.Code, w.Header().Get("Upload-Offset"))
+	}
+}
+
+func TestTusExpiration(t *testing.T) {
+	openTestStorage(t)
+	saved := tusExpireAfter
+	tusExpireAfter = time.Hour
+	t.Cleanup(func() { tusExpireAfter = saved })
+
+	fresh := createTusUpload(t, 10)
+	stale := createTusUpload(t, 10)
+	staleUpload, err := loadTusUpload(strings.TrimPrefix(stale, "/uploads/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Загрузка создана и последний раз дописывалась два часа назад
+	old := time.Now().Add(-2 * time.Hour)
+	db.Exec(`UPDATE tus_uploads SET created_at = datetime('now', '-2 hours') WHERE id = ?`, staleUpload.ID)
+	os.Chtimes(staleUpload.partPath(), old, old)
+	// Файл частей без записи в БД: сервис остановился посреди создания загрузки
+	orphan := tusUpload{ID: newTusID()}
+	os.WriteFile(orphan.partPath(), []byte("x"), 0644)
+	os.Chtimes(orphan.partPath(), old, old)
+
+	if w := tusRequest(http.MethodHead, stale, "", nil); w.Code != http.StatusGone {
+		t.Fatalf("HEAD просроченной загрузки: код %d", w.Code)
+	}
+	if w := tusRequest(http.MethodPatch, stale, "x", map[string]string{"Upload-Offset": "0"}); w.Code != http.StatusGone {
+		t.Fatalf("PATCH просроченной загрузки: код %d", w.Code)
+	}
+
+	expireTusUploads()
+	if w := tusRequest(http.MethodHead, stale, "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("просроченная загрузка не удалена: код %d", w.Code)
+	}
+	for _, path := range []string{staleUpload.partPath(), orphan.partPath()} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("файл частей %s не удалён", path)
+		}
+	}
+	if w := tusRequest(http.MethodHead, fresh, "", nil); w.Code != http.StatusOK {
+		t.Errorf("свежая загрузка: код %d", w.Code)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Все работы одного студента по одному заданию — версии одной сдачи: version считается с 1
+// в порядке загрузки. Студент или преподаватель может отметить одну из версий как итоговую
+// (final); текущая версия сдачи — итоговая, а если её нет, последняя. С текущими версиями
+// других студентов сервис анализа и сравнивает работу, а не со всеми черновиками.
+//
+//	GET    /files/{id}/versions — все версии сдачи, к которой относится работа
+//	POST   /files/{id}/final    — сделать версию итоговой
+//	DELETE /files/{id}/final    — снять отметку: текущей снова станет последняя версия
+
+// currentVersionCond — условие «работа files — текущая версия своей сдачи».
+const currentVersionCond = `(files.is_final = 1 OR (NOT EXISTS (
+		SELECT 1 FROM files v WHERE v.student_id = files.student_id AND v.assignment_id = files.assignment_id
+		AND (v.is_final = 1 OR v.id > files.id))))`
+
+// nextVersion — подзапрос номера следующей версии для INSERT (параметры: студент, задание).
+const nextVersion = `(SELECT COALESCE(MAX(version), 0) + 1 FROM files WHERE student_id = ? AND assignment_id = ?)`
+
+// backfillVersions нумерует работы, загруженные до появления версий.
+func backfillVersions() {
+	query := `
+	UPDATE files SET version = (
+		SELECT COUNT(*) FROM files v
+		WHERE v.student_id = files.student_id AND v.assignment_id = files.assignment_id AND v.id <= files.id
+	)
+	WHERE version IS NULL
+	`
+	result, err := db.Exec(query)
+	if err != nil {
+		fmt.Println("Ошибка при нумерации версий работ:", err)
+		return
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		fmt.Printf("Пронумерованы версии работ: %d\n", n)
+	}
+}
+
+// fileVersionsHandler возвращает все версии сдачи, к которой относится работа id.
+func fileVersionsHandler(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Only GET method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	var studentID, assignmentID string
+	err := db.QueryRow(`SELECT student_id, assignment_id FROM files WHERE id = ?`, id).Scan(&studentID, &assignmentID)
+	if err == sql.ErrNoRows {
+		http.Error(w, `Файл не найден`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
+	query := `SELECT ` + fileColumns + ` FROM files WHERE student_id = ? AND assignment_id = ? ORDER BY version ASC, id ASC`
+	rows, err := db.Query(query, studentID, assignmentID)
+	if err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	versions := []FileInfo{}
+	for rows.Next() {
+		file, err := scanFileInfo(rows)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, file)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"student_id":    studentID,
+		"assignment_id": assignmentID,
+		"versions":      versions,
+	})
+}
+
+// fileFinalHandler отмечает версию итоговой (POST) или снимает отметку (DELETE). Итоговой
+// может быть только одна версия сдачи, поэтому отметка с других версий снимается.
+func fileFinalHandler(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		http.Error(w, "Only POST and DELETE methods are allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	tx, err := db.Begin()
+	if err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	var studentID, assignmentID string
+	err = tx.QueryRow(`SELECT student_id, assignment_id FROM files WHERE id = ?`, id).Scan(&studentID, &assignmentID)
+	if err == sql.ErrNoRows {
+		http.Error(w, `Файл не найден`, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
+	if r.Method == http.MethodPost {
+		_, err = tx.Exec(`UPDATE files SET is_final = 0 WHERE student_id = ? AND assignment_id = ? AND id != ?`, studentID, assignmentID, id)
+		if err == nil {
+			_, err = tx.Exec(`UPDATE files SET is_final = 1 WHERE id = ?`, id)
+		}
+	} else {
+		_, err = tx.Exec(`UPDATE files SET is_final = 0 WHERE id = ?`, id)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		fmt.Println("Ошибка при смене итоговой версии:", err)
+		http.Error(w, `Ошибка при сохранении данных в БД`, http.StatusInternalServerError)
+		return
+	}
+	file, err := scanFileInfo(db.QueryRow(`SELECT `+fileColumns+` FROM files WHERE id = ?`, id))
+	if err != nil {
+		http.Error(w, `Ошибка при запросе к БД`, http.StatusInternalServerError)
+		return
+	}
+	if r.Method == http.MethodPost {
+		fmt.Printf("Версия %d работы студента %s по заданию %s отмечена итоговой (File ID %s)\n", file.Version, studentID, assignmentID, id)
+	}
+	json.NewEncoder(w).Encode(file)
+}
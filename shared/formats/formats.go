@@ -0,0 +1,140 @@
+// Package formats — реестр поддерживаемых типов файлов, общий для сервисов хранения и
+// анализа. Реестр загружается из formats.json в корне репозитория.
+package formats
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Format описывает поддерживаемый тип файла.
+type Format struct {
+	Name       string         `json:"name"`
+	Extensions []string       `json:"extensions"`
+	MIME       string         `json:"mime"`
+	Language   string         `json:"language,omitempty"`
+	Comments   *CommentSyntax `json:"comments,omitempty"`
+	// Tokenizer — как сравнивать содержимое: text — как текст, code — как код без
+	// комментариев, notebook — блокнот Jupyter с ячейками кода и markdown.
+	Tokenizer string `json:"tokenizer,omitempty"`
+	// Extractor — как получить текст: plain — файл уже текстовый, docx/odt/pdf — извлечь
+	// из документа, zip/tar.gz — распаковать проект.
+	Extractor string `json:"extractor"`
+}
+
+// CommentSyntax — синтаксис комментариев языка.
+type CommentSyntax struct {
+	Line  []string    `json:"line,omitempty"`
+	Block [][2]string `json:"block,omitempty"`
+}
+
+var formats []Format
+
+// Load читает реестр форматов из FORMATS_CONFIG, /app/formats.json
+// или ../formats.json при локальном запуске из каталога сервиса.
+func Load() {
+	path := os.Getenv("FORMATS_CONFIG")
+	if path == "" {
+		path = "/app/formats.json"
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			path = "../formats.json"
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		panic("Ошибка чтения реестра форматов: " + err.Error())
+	}
+	var config struct {
+		Formats []Format `json:"formats"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		panic("Ошибка разбора реестра форматов: " + err.Error())
+	}
+	for i, f := range config.Formats {
+		switch f.Tokenizer {
+		case "", "text", "code", "notebook":
+		default:
+			panic(fmt.Sprintf("Формат %s: неизвестный токенизатор %q", f.Name, f.Tokenizer))
+		}
+		if f.Extractor == "" {
+			panic(fmt.Sprintf("Формат %s: не указан способ извлечения текста", f.Name))
+		}
+		for j, ext := range f.Extensions {
+			config.Formats[i].Extensions[j] = strings.ToLower(ext)
+		}
+	}
+	formats = config.Formats
+	fmt.Printf("Загружен реестр форматов %s: %d форматов\n", path, len(formats))
+}
+
+// All возвращает все форматы реестра.
+func All() []Format {
+	return formats
+}
+
+// For возвращает формат файла по самому длинному совпавшему расширению
+// (чтобы .tar.gz не путался с .gz) или nil, если формат не поддерживается.
+func For(filename string) *Format {
+	lower := strings.ToLower(filename)
+	var found *Format
+	longest := 0
+	for i, f := range formats {
+		for _, ext := range f.Extensions {
+			if len(ext) > longest && strings.HasSuffix(lower, ext) {
+				found, longest = &formats[i], len(ext)
+			}
+		}
+	}
+	return found
+}
+
+// ByName возвращает формат по имени или nil.
+func ByName(name string) *Format {
+	for i, f := range formats {
+		if f.Name == name {
+			return &formats[i]
+		}
+	}
+	return nil
+}
+
+// IsSource — текстовый файл, который анализируется как есть и допускается внутри архива.
+func (f *Format) IsSource() bool {
+	return f.Extractor == "plain"
+}
+
+func (f *Format) IsArchive() bool {
+	return f.Extractor == "zip" || f.Extractor == "tar.gz"
+}
+
+// IsSourceFile сообщает, можно ли анализировать файл как есть. Документы приходят
+// на анализ уже извлечённым текстом (.txt), а архивы — распакованным каталогом.
+func IsSourceFile(path string) bool {
+	f := For(path)
+	return f != nil && f.IsSource()
+}
+
+// TokenizerFor возвращает токенизатор файла; неизвестные файлы сравниваются как текст.
+func TokenizerFor(path string) string {
+	if f := For(path); f != nil && f.Tokenizer != "" {
+		return f.Tokenizer
+	}
+	return "text"
+}
+
+// ExtensionList перечисляет расширения для сообщений об ошибках; sourceOnly оставляет
+// только файлы, которые анализируются как есть.
+func ExtensionList(sourceOnly bool) string {
+	var exts []string
+	for _, f := range formats {
+		if sourceOnly && !f.IsSource() {
+			continue
+		}
+		for _, ext := range f.Extensions {
+			exts = append(exts, strings.TrimPrefix(ext, "."))
+		}
+	}
+	return strings.Join(exts, ", ")
+}
@@ -0,0 +1,289 @@
+// Package migrate применяет версионированные SQL-миграции к SQLite БД сервиса. Миграция —
+// пара файлов NNNN_имя.up.sql (вносит изменение) и NNNN_имя.down.sql (откатывает его);
+// применённые версии записываются в таблицу schema_migrations.
+package migrate
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Column — колонка, которую версии сервиса до миграций добавляли в существующую таблицу
+// при запуске. В БД такой версии её может не хватать, а первая миграция таблицу, которая
+// уже есть, не трогает.
+type Column struct {
+	Table      string
+	Name       string
+	Definition string
+}
+
+// Migrator применяет миграции из Files к DB.
+type Migrator struct {
+	DB *sql.DB
+	// Files — каталог с файлами миграций, обычно встроенный в бинарник через embed.
+	Files  fs.FS
+	Legacy []Column
+}
+
+type migration struct {
+	version int
+	name    string
+	up      string
+	down    string
+}
+
+// load читает миграции по возрастанию версии. У каждой должны быть обе части.
+func (m *Migrator) load() ([]migration, error) {
+	entries, err := fs.ReadDir(m.Files, ".")
+	if err != nil {
+		return nil, fmt.Errorf("чтение миграций: %v", err)
+	}
+	byVersion := map[int]*migration{}
+	for _, entry := range entries {
+		name := entry.Name()
+		base, direction, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), ".")
+		number, _, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(number)
+		if !ok || err != nil || version <= 0 || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("некорректное имя миграции: %s", name)
+		}
+		data, err := fs.ReadFile(m.Files, name)
+		if err != nil {
+			return nil, fmt.Errorf("чтение миграции %s: %v", name, err)
+		}
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &migration{version: version, name: base}
+			byVersion[version] = mig
+		}
+		if mig.name != base {
+			return nil, fmt.Errorf("две миграции с версией %d: %s и %s", version, mig.name, base)
+		}
+		if direction == "up" {
+			mig.up = string(data)
+		} else {
+			mig.down = string(data)
+		}
+	}
+	migrations := make([]migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.up == "" || mig.down == "" {
+			return nil, fmt.Errorf("у миграции %s нет up или down части", mig.name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+func (m *Migrator) tableExists(name string) (bool, error) {
+	var n int
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&n)
+	return n > 0, err
+}
+
+// EnsureColumn добавляет колонку в уже существующую таблицу, созданную старой версией сервиса.
+func EnsureColumn(db *sql.DB, table string, column string, definition string) error {
+	_, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil && !strings.Contains(err.Error(), "duplicate column") {
+		return fmt.Errorf("добавление колонки %s: %v", column, err)
+	}
+	return nil
+}
+
+// prepare создаёт schema_migrations. Если её ещё нет, а таблицы уже есть, БД создана
+// версией сервиса до миграций: ей сначала добавляются недостающие колонки.
+func (m *Migrator) prepare() error {
+	tracked, err := m.tableExists("schema_migrations")
+	if err != nil || tracked {
+		return err
+	}
+	for _, c := range m.Legacy {
+		exists, err := m.tableExists(c.Table)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		if err := EnsureColumn(m.DB, c.Table, c.Name, c.Definition); err != nil {
+			return err
+		}
+	}
+	query := `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)
+	`
+	_, err = m.DB.Exec(query)
+	return err
+}
+
+// applied возвращает время применения по версиям.
+func (m *Migrator) applied() (map[int]string, error) {
+	rows, err := m.DB.Query(`SELECT version, COALESCE(applied_at, '') FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := map[int]string{}
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+// state готовит schema_migrations и возвращает известные и применённые миграции.
+func (m *Migrator) state() ([]migration, map[int]string, error) {
+	migrations, err := m.load()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := m.prepare(); err != nil {
+		return nil, nil, err
+	}
+	applied, err := m.applied()
+	return migrations, applied, err
+}
+
+// run выполняет скрипт миграции и обновляет schema_migrations в одной транзакции.
+func (m *Migrator) run(mig migration, up bool) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	script, record, args := mig.down, `DELETE FROM schema_migrations WHERE version = ?`, []interface{}{mig.version}
+	if up {
+		script, record, args = mig.up, `INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, []interface{}{mig.version, mig.name}
+	}
+	if _, err := tx.Exec(script); err != nil {
+		return fmt.Errorf("%s: %v", mig.name, err)
+	}
+	if _, err := tx.Exec(record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Up применяет ещё не применённые миграции до версии target (0 — все) и возвращает,
+// сколько применено.
+func (m *Migrator) Up(target int) (int, error) {
+	migrations, applied, err := m.state()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, mig := range migrations {
+		if applied[mig.version] != "" || (target > 0 && mig.version > target) {
+			continue
+		}
+		if err := m.run(mig, true); err != nil {
+			return count, err
+		}
+		fmt.Println("Применена миграция", mig.name)
+		count++
+	}
+	return count, nil
+}
+
+// Down откатывает steps последних применённых миграций и возвращает, сколько откачено.
+func (m *Migrator) Down(steps int) (int, error) {
+	migrations, applied, err := m.state()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for i := len(migrations) - 1; i >= 0 && count < steps; i-- {
+		mig := migrations[i]
+		if applied[mig.version] == "" {
+			continue
+		}
+		if err := m.run(mig, false); err != nil {
+			return count, err
+		}
+		fmt.Println("Откачена миграция", mig.name)
+		count++
+	}
+	return count, nil
+}
+
+// PrintStatus печатает, какие миграции применены.
+func (m *Migrator) PrintStatus() error {
+	migrations, applied, err := m.state()
+	if err != nil {
+		return err
+	}
+	known := map[int]bool{}
+	for _, mig := range migrations {
+		known[mig.version] = true
+		if appliedAt := applied[mig.version]; appliedAt != "" {
+			fmt.Printf("%-30s применена %s\n", mig.name, appliedAt)
+		} else {
+			fmt.Printf("%-30s не применена\n", mig.name)
+		}
+	}
+	for version := range applied {
+		if !known[version] {
+			fmt.Printf("%04d%-26s применена, но неизвестна этой версии сервиса\n", version, "")
+		}
+	}
+	return nil
+}
+
+// Run — подкоманда migrate up [версия] | down [N] | status.
+func (m *Migrator) Run(args []string) {
+	usage := func() {
+		fmt.Println("Использование: migrate up [версия] | migrate down [N] | migrate status")
+		os.Exit(2)
+	}
+	if len(args) == 0 || len(args) > 2 {
+		usage()
+	}
+	number := 0
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 || args[0] == "status" {
+			usage()
+		}
+		number = n
+	}
+	var err error
+	switch args[0] {
+	case "up":
+		var count int
+		count, err = m.Up(number)
+		if err == nil {
+			fmt.Println("Применено миграций:", count)
+		}
+	case "down":
+		if number == 0 {
+			number = 1
+		}
+		var count int
+		count, err = m.Down(number)
+		if err == nil {
+			fmt.Println("Откачено миграций:", count)
+		}
+	case "status":
+		err = m.PrintStatus()
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Println("Ошибка миграции:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,44 @@
+// Package notebook разбирает блокноты Jupyter (.ipynb) одинаково для сервисов
+// хранения и анализа. Выводы ячеек и номера запусков не читаются: они не относятся
+// к тому, что написал студент.
+package notebook
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Cell — ячейка блокнота: тип (code, markdown, raw) и её текст.
+type Cell struct {
+	Type   string
+	Source string
+}
+
+// Parse возвращает ячейки блокнота в порядке следования.
+func Parse(data []byte) ([]Cell, error) {
+	var nb struct {
+		Cells []struct {
+			CellType string          `json:"cell_type"`
+			Source   json.RawMessage `json:"source"`
+		} `json:"cells"`
+	}
+	if err := json.Unmarshal(data, &nb); err != nil {
+		return nil, err
+	}
+	cells := make([]Cell, len(nb.Cells))
+	for i, cell := range nb.Cells {
+		cells[i] = Cell{Type: cell.CellType, Source: cellSource(cell.Source)}
+	}
+	return cells, nil
+}
+
+// cellSource склеивает текст ячейки: в .ipynb он хранится строкой или массивом строк.
+func cellSource(raw json.RawMessage) string {
+	var lines []string
+	if err := json.Unmarshal(raw, &lines); err == nil {
+		return strings.Join(lines, "")
+	}
+	var text string
+	json.Unmarshal(raw, &text)
+	return text
+}
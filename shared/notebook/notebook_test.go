@@ -0,0 +1,31 @@
+package notebook
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	data := []byte(`{"cells": [
+		{"cell_type": "markdown", "source": ["# Задача\n", "Сумма списка"]},
+		{"cell_type": "code", "source": "print(sum([1, 2]))", "outputs": [{"text": "3"}], "execution_count": 1},
+		{"cell_type": "code", "source": []},
+		{"cell_type": "raw"}
+	]}`)
+	got, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Cell{
+		{Type: "markdown", Source: "# Задача\nСумма списка"},
+		{Type: "code", Source: "print(sum([1, 2]))"},
+		{Type: "code", Source: ""},
+		{Type: "raw", Source: ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("получено %+v, ожидалось %+v", got, want)
+	}
+	if _, err := Parse([]byte(`print(1)`)); err == nil {
+		t.Error("не JSON: ошибка не возвращена")
+	}
+}
@@ -0,0 +1,240 @@
+// Package pagination — постраничная выдача списков и фильтры для SQL-запросов списков.
+// Курсор — непрозрачная строка со значением поля сортировки и ID последней записи страницы:
+// следующая страница начинается сразу после неё в выбранном порядке сортировки, поэтому
+// новые записи не сдвигают страницы, как при offset, а удаление самой записи не ломает курсор.
+package pagination
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+// Params — параметры страницы списка.
+type Params struct {
+	Limit     int
+	SortField string // имя поля из запроса
+	Sort      string // SQL-выражение, по которому сортируется список
+	Desc      bool
+	After     *Cursor // последняя запись предыдущей страницы, nil — первая страница
+}
+
+// Cursor — позиция в списке: поле сортировки, значение выражения сортировки у последней
+// записи страницы и её ID. Значение хранится текстом вместе с типом SQLite и в запрос
+// передаётся значением того же типа, чтобы сравниваться с выражением так же, как в ORDER BY.
+type Cursor struct {
+	Sort  string `json:"s"`
+	Type  string `json:"t"` // typeof(): integer, real, text или null
+	Value string `json:"v,omitempty"`
+	ID    int64  `json:"id"`
+}
+
+// ParseParams читает limit, cursor и sort (имя поля, с минусом — по убыванию).
+func ParseParams(q url.Values, sortable map[string]string, defaultSort string) (Params, error) {
+	p := Params{Limit: DefaultPageSize}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxPageSize {
+			return p, fmt.Errorf("limit должен быть числом от 1 до %d", MaxPageSize)
+		}
+		p.Limit = n
+	}
+	field := q.Get("sort")
+	if field == "" {
+		field = defaultSort
+	}
+	p.SortField = field
+	if strings.HasPrefix(field, "-") {
+		p.Desc = true
+		field = field[1:]
+	}
+	expr, ok := sortable[field]
+	if !ok {
+		names := make([]string, 0, len(sortable))
+		for name := range sortable {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return p, fmt.Errorf("сортировка по %q не поддерживается, доступны: %s", field, strings.Join(names, ", "))
+	}
+	p.Sort = expr
+	if v := q.Get("cursor"); v != "" {
+		c, err := decodeCursor(v)
+		if err != nil {
+			return p, errors.New("некорректный cursor")
+		}
+		// Значение поля одной сортировки бессмысленно сравнивать с другим полем
+		if c.Sort != p.SortField {
+			return p, errors.New("cursor получен для другой сортировки, начните список с первой страницы")
+		}
+		p.After = &c
+	}
+	return p, nil
+}
+
+// OrderBy — ORDER BY по выбранному полю с ID для однозначного порядка.
+func (p Params) OrderBy(idColumn string) string {
+	dir := "ASC"
+	if p.Desc {
+		dir = "DESC"
+	}
+	return fmt.Sprintf(" ORDER BY %s %s, %s %s", p.Sort, dir, idColumn, dir)
+}
+
+// Querier — *sql.DB или *sql.Tx.
+type Querier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// NextCursor возвращает курсор страницы, которая начинается после записи id: значение
+// выражения сортировки читается из from (таблица с псевдонимом, как в запросе списка).
+func (p Params) NextCursor(q Querier, from string, idColumn string, id int64) (string, error) {
+	c := Cursor{Sort: p.SortField, ID: id}
+	var text sql.NullString
+	var real sql.NullFloat64
+	// Дробное число как текст SQLite округляет до 15 знаков, поэтому читается отдельно
+	query := fmt.Sprintf("SELECT typeof(%[1]s), CAST(%[1]s AS TEXT), CAST(%[1]s AS REAL) FROM %[2]s WHERE %[3]s = ?", p.Sort, from, idColumn)
+	if err := q.QueryRow(query, id).Scan(&c.Type, &text, &real); err != nil {
+		return "", err
+	}
+	c.Value = text.String
+	if c.Type == "real" {
+		c.Value = strconv.FormatFloat(real.Float64, 'g', -1, 64)
+	}
+	return encodeCursor(c), nil
+}
+
+func encodeCursor(c Cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (Cursor, error) {
+	var c Cursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return c, err
+	}
+	if c.Type == "null" || c.Type == "text" {
+		return c, nil
+	}
+	_, err = c.arg()
+	return c, err
+}
+
+// arg — значение курсора для параметра запроса с исходным типом SQLite.
+func (c Cursor) arg() (interface{}, error) {
+	switch c.Type {
+	case "integer":
+		return strconv.ParseInt(c.Value, 10, 64)
+	case "real":
+		return strconv.ParseFloat(c.Value, 64)
+	case "text":
+		return c.Value, nil
+	}
+	return nil, fmt.Errorf("неизвестный тип значения %q", c.Type)
+}
+
+// Filters собирает условия WHERE из параметров запроса; Args — их параметры по порядку.
+type Filters struct {
+	conds []string
+	Args  []interface{}
+}
+
+func (f *Filters) Add(cond string, args ...interface{}) {
+	f.conds = append(f.conds, cond)
+	f.Args = append(f.Args, args...)
+}
+
+// AddCursor оставляет только записи после курсора p в порядке OrderBy(idColumn). NULL
+// в SQLite меньше любого значения: при сортировке по возрастанию такие записи идут первыми,
+// по убыванию — последними.
+func (f *Filters) AddCursor(p Params, idColumn string) {
+	c := p.After
+	if c == nil {
+		return
+	}
+	op := ">"
+	if p.Desc {
+		op = "<"
+	}
+	if c.Type == "null" {
+		if p.Desc {
+			f.Add(fmt.Sprintf("(%s IS NULL AND %s < ?)", p.Sort, idColumn), c.ID)
+		} else {
+			f.Add(fmt.Sprintf("((%[1]s IS NULL AND %[2]s > ?) OR %[1]s IS NOT NULL)", p.Sort, idColumn), c.ID)
+		}
+		return
+	}
+	cond := fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND %[3]s %[2]s ?)", p.Sort, op, idColumn)
+	if p.Desc {
+		cond += fmt.Sprintf(" OR %s IS NULL", p.Sort)
+	}
+	value, _ := c.arg()
+	f.Add(cond+")", value, value, c.ID)
+}
+
+// Where — условие WHERE или пустая строка, если фильтров нет.
+func (f *Filters) Where() string {
+	if len(f.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(f.conds, " AND ")
+}
+
+// AddTimeRange добавляет фильтр по диапазону дат from..to включительно. Даты принимаются
+// как 2024-12-10 или 2024-12-10T15:30:27Z; дата без времени в to означает конец дня.
+func (f *Filters) AddTimeRange(q url.Values, column string, fromParam string, toParam string) error {
+	if v := q.Get(fromParam); v != "" {
+		t, _, err := ParseTime(v)
+		if err != nil {
+			return fmt.Errorf("%s: %v", fromParam, err)
+		}
+		f.Add(column+" >= ?", t.Format(SQLiteTimeLayout))
+	}
+	if v := q.Get(toParam); v != "" {
+		t, dateOnly, err := ParseTime(v)
+		if err != nil {
+			return fmt.Errorf("%s: %v", toParam, err)
+		}
+		if dateOnly {
+			t = t.Add(24 * time.Hour)
+		} else {
+			t = t.Add(time.Second)
+		}
+		f.Add(column+" < ?", t.Format(SQLiteTimeLayout))
+	}
+	return nil
+}
+
+// SQLiteTimeLayout — формат CURRENT_TIMESTAMP в SQLite (UTC).
+const SQLiteTimeLayout = "2006-01-02 15:04:05"
+
+// ParseTime разбирает дату из параметра запроса; второй результат — дата указана без времени.
+func ParseTime(v string) (time.Time, bool, error) {
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.UTC(), false, nil
+	}
+	if t, err := time.Parse(SQLiteTimeLayout, v); err == nil {
+		return t, false, nil
+	}
+	return time.Time{}, false, errors.New("ожидается дата вида 2024-12-10 или 2024-12-10T15:30:27Z")
+}
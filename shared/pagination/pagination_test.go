@@ -0,0 +1,123 @@
+package pagination
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testSortable = map[string]string{"id": "id", "size": "COALESCE(size, 0)", "score": "r.score"}
+
+func TestParseParams(t *testing.T) {
+	sizeCursor := encodeCursor(Cursor{Sort: "-size", Type: "integer", Value: "42", ID: 7})
+	cases := []struct {
+		name  string
+		query string
+		want  Params
+		err   string
+	}{
+		{"по умолчанию", "", Params{Limit: DefaultPageSize, SortField: "id", Sort: "id"}, ""},
+		{"по убыванию с курсором", "sort=-size&limit=10&cursor=" + sizeCursor,
+			Params{Limit: 10, SortField: "-size", Sort: "COALESCE(size, 0)", Desc: true,
+				After: &Cursor{Sort: "-size", Type: "integer", Value: "42", ID: 7}}, ""},
+		{"limit 0", "limit=0", Params{}, "limit должен быть числом"},
+		{"limit больше максимума", "limit=501", Params{}, "limit должен быть числом"},
+		{"неизвестное поле", "sort=name", Params{}, "доступны: id, score, size"},
+		{"курсор не base64", "cursor=%21%21", Params{}, "некорректный cursor"},
+		{"курсор старого формата", "cursor=" + "MTIz", Params{}, "некорректный cursor"},
+		{"курсор с нечисловым значением", "sort=-size&cursor=" + encodeCursor(Cursor{Sort: "-size", Type: "integer", Value: "x"}), Params{}, "некорректный cursor"},
+		// Курсор списка, отсортированного по убыванию размера, не подходит к сортировке по ID
+		{"курсор другой сортировки", "cursor=" + sizeCursor, Params{}, "другой сортировки"},
+	}
+	for _, c := range cases {
+		q, _ := url.ParseQuery(c.query)
+		got, err := ParseParams(q, testSortable, "id")
+		if c.err != "" {
+			if err == nil || !strings.Contains(err.Error(), c.err) {
+				t.Errorf("%s: ошибка %v, ожидалось %q", c.name, err, c.err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: получено %+v, %v; ожидалось %+v", c.name, got, err, c.want)
+		}
+	}
+}
+
+func TestAddCursor(t *testing.T) {
+	cases := []struct {
+		name   string
+		params Params
+		cond   string
+		args   []interface{}
+	}{
+		{"первая страница", Params{Sort: "id"}, "", nil},
+		{"по возрастанию", Params{Sort: "id", After: &Cursor{Type: "integer", Value: "5", ID: 5}},
+			" WHERE (id > ? OR (id = ? AND id > ?))", []interface{}{int64(5), int64(5), int64(5)}},
+		// Дробное значение передаётся числом без потери точности, а NULL идут после всех значений
+		{"по убыванию", Params{Sort: "r.score", Desc: true, After: &Cursor{Type: "real", Value: "0.6666666666666666", ID: 3}},
+			" WHERE (r.score < ? OR (r.score = ? AND r.id < ?) OR r.score IS NULL)", []interface{}{0.6666666666666666, 0.6666666666666666, int64(3)}},
+		{"текст", Params{Sort: "student_id", After: &Cursor{Type: "text", Value: "std_1", ID: 2}},
+			" WHERE (student_id > ? OR (student_id = ? AND r.id > ?))", []interface{}{"std_1", "std_1", int64(2)}},
+		{"NULL по возрастанию", Params{Sort: "r.score", After: &Cursor{Type: "null", ID: 4}},
+			" WHERE ((r.score IS NULL AND r.id > ?) OR r.score IS NOT NULL)", []interface{}{int64(4)}},
+		{"NULL по убыванию", Params{Sort: "r.score", Desc: true, After: &Cursor{Type: "null", ID: 4}},
+			" WHERE (r.score IS NULL AND r.id < ?)", []interface{}{int64(4)}},
+	}
+	for _, c := range cases {
+		var f Filters
+		idColumn := "r.id"
+		if c.params.Sort == "id" {
+			idColumn = "id"
+		}
+		f.AddCursor(c.params, idColumn)
+		if f.Where() != c.cond || !reflect.DeepEqual(f.Args, c.args) {
+			t.Errorf("%s: получено %q %v, ожидалось %q %v", c.name, f.Where(), f.Args, c.cond, c.args)
+		}
+	}
+}
+
+func TestOrderBy(t *testing.T) {
+	if got := (Params{Sort: "r.score", Desc: true}).OrderBy("r.id"); got != " ORDER BY r.score DESC, r.id DESC" {
+		t.Errorf("получено %q", got)
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	cases := []struct {
+		value    string
+		want     string
+		dateOnly bool
+	}{
+		{"2024-12-10", "2024-12-10 00:00:00", true},
+		{"2024-12-10T18:30:27+03:00", "2024-12-10 15:30:27", false},
+		{"2024-12-10 15:30:27", "2024-12-10 15:30:27", false},
+	}
+	for _, c := range cases {
+		got, dateOnly, err := ParseTime(c.value)
+		if err != nil || got.Format(SQLiteTimeLayout) != c.want || dateOnly != c.dateOnly {
+			t.Errorf("ParseTime(%s) = %v, %v, %v", c.value, got, dateOnly, err)
+		}
+	}
+	if _, _, err := ParseTime("10.12.2024"); err == nil {
+		t.Error("ParseTime(10.12.2024): ошибка не возвращена")
+	}
+}
+
+func TestAddTimeRange(t *testing.T) {
+	var f Filters
+	q, _ := url.ParseQuery("from=2024-12-10&to=2024-12-11")
+	if err := f.AddTimeRange(q, "created_at", "from", "to"); err != nil {
+		t.Fatal(err)
+	}
+	// Дата без времени в to — до конца дня включительно
+	want := []interface{}{"2024-12-10 00:00:00", "2024-12-12 00:00:00"}
+	if f.Where() != " WHERE created_at >= ? AND created_at < ?" || !reflect.DeepEqual(f.Args, want) {
+		t.Errorf("получено %q %v", f.Where(), f.Args)
+	}
+	q, _ = url.ParseQuery("to=вчера")
+	if err := f.AddTimeRange(q, "created_at", "from", "to"); err == nil || !strings.HasPrefix(err.Error(), "to:") {
+		t.Errorf("некорректная дата: %v", err)
+	}
+}